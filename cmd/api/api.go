@@ -2,14 +2,18 @@ package api
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
 	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/cmd/api/middleware"
 	"github.com/C0d3-5t3w/aServ/internal/config"
+	"github.com/C0d3-5t3w/aServ/internal/mailer"
+	"github.com/C0d3-5t3w/aServ/internal/policy"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
 	"github.com/C0d3-5t3w/aServ/internal/storage"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -30,18 +34,28 @@ type ItemRequest struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
-}
-
-type TagRequest struct {
-	Name string `json:"name"`
+	CategoryID  string  `json:"category_id"`
 }
 
 var cfg *config.Config
-var st *storage.Storage
+var st storage.Store
+var keyring *crypto.Keyring
+
+// accountPolicy decides which passwords and usernames are acceptable.
+var accountPolicy *policy.Policy
 
-func RegisterRoutes(router *mux.Router, config *config.Config, store *storage.Storage) {
+// mail sends verification and password reset emails. It is nil when no mail
+// driver is configured.
+var mail mailer.Mailer
+
+func RegisterRoutes(router *mux.Router, config *config.Config, store storage.Store, keys *crypto.Keyring, mailSender mailer.Mailer, rules *policy.Policy) {
 	cfg = config
 	st = store
+	keyring = keys
+	mail = mailSender
+	accountPolicy = rules
+
+	authenticate := middleware.AuthMiddleware(cfg, st, keyring)
 
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
@@ -50,34 +64,103 @@ func RegisterRoutes(router *mux.Router, config *config.Config, store *storage.St
 	authRouter := apiRouter.PathPrefix("/auth").Subrouter()
 	authRouter.HandleFunc("/login", loginHandler).Methods("POST")
 	authRouter.HandleFunc("/register", registerHandler).Methods("POST")
+	authRouter.HandleFunc("/refresh", refreshHandler).Methods("POST")
+	authRouter.HandleFunc("/2fa/verify", verifyTwoFactorHandler).Methods("POST")
+	authRouter.HandleFunc("/verify-email", verifyEmailHandler).Methods("POST")
+	authRouter.HandleFunc("/password-reset", requestPasswordResetHandler).Methods("POST")
+	authRouter.HandleFunc("/password-reset/confirm", confirmPasswordResetHandler).Methods("POST")
+	authRouter.Handle("/logout", authenticate(sessionOnly(logoutHandler))).Methods("POST")
+	authRouter.Handle("/logout-all", authenticate(sessionOnly(logoutAllHandler))).Methods("POST")
+	authRouter.Handle("/sessions", authenticate(sessionOnly(listSessionsHandler))).Methods("GET")
+	authRouter.Handle("/sessions/{id}", authenticate(sessionOnly(revokeSessionHandler))).Methods("DELETE")
+
+	meRouter := apiRouter.PathPrefix("/me").Subrouter()
+	meRouter.Use(authenticate)
+	meRouter.HandleFunc("", getMeHandler).Methods("GET")
+	meRouter.Handle("", sessionOnly(updateMeHandler)).Methods("PUT")
+	meRouter.Handle("/password", sessionOnly(changePasswordHandler)).Methods("POST")
+	meRouter.Handle("/verify-email", sessionOnly(resendVerificationHandler)).Methods("POST")
+	meRouter.Handle("/api-keys", sessionOnly(listAPIKeysHandler)).Methods("GET")
+	meRouter.Handle("/api-keys", sessionOnly(createAPIKeyHandler)).Methods("POST")
+	meRouter.Handle("/api-keys/{id}", sessionOnly(revokeAPIKeyHandler)).Methods("DELETE")
+	meRouter.Handle("/2fa", sessionOnly(getTwoFactorHandler)).Methods("GET")
+	meRouter.Handle("/2fa/setup", sessionOnly(setupTwoFactorHandler)).Methods("POST")
+	meRouter.Handle("/2fa/enable", sessionOnly(enableTwoFactorHandler)).Methods("POST")
+	meRouter.Handle("/2fa/disable", sessionOnly(disableTwoFactorHandler)).Methods("POST")
+	meRouter.Handle("/2fa/recovery-codes", sessionOnly(regenerateRecoveryCodesHandler)).Methods("POST")
 
 	usersRouter := apiRouter.PathPrefix("/users").Subrouter()
-	usersRouter.Use(authMiddleware)
-	usersRouter.HandleFunc("", listUsersHandler).Methods("GET")
-	usersRouter.HandleFunc("/{id}", getUserHandler).Methods("GET")
+	usersRouter.Use(authenticate)
+	usersRouter.Handle("", allow(rbac.UsersRead, listUsersHandler)).Methods("GET")
+	usersRouter.Handle("/{id}", allow(rbac.UsersRead, getUserHandler)).Methods("GET")
+	usersRouter.Handle("/{id}", allow(rbac.UsersManage, updateUserHandler)).Methods("PUT")
+	usersRouter.Handle("/{id}", allow(rbac.UsersManage, deleteUserHandler)).Methods("DELETE")
+	usersRouter.Handle("/{id}/role", allow(rbac.RolesManage, updateUserRoleHandler)).Methods("PUT")
+	usersRouter.Handle("/{id}/disable", allow(rbac.UsersManage, disableUserHandler)).Methods("POST")
+	usersRouter.Handle("/{id}/enable", allow(rbac.UsersManage, enableUserHandler)).Methods("POST")
+	usersRouter.Handle("/{id}/reset-password", allow(rbac.UsersManage, resetUserPasswordHandler)).Methods("POST")
+	usersRouter.Handle("/{id}/sessions", allow(rbac.UsersManage, revokeUserSessionsHandler)).Methods("DELETE")
+	usersRouter.Handle("/{id}/2fa", allow(rbac.UsersManage, resetUserTwoFactorHandler)).Methods("DELETE")
 
 	itemsRouter := apiRouter.PathPrefix("/items").Subrouter()
-	itemsRouter.Use(authMiddleware)
-	itemsRouter.HandleFunc("", listItemsHandler).Methods("GET")
-	itemsRouter.HandleFunc("", createItemHandler).Methods("POST")
-	itemsRouter.HandleFunc("/{id}", getItemHandler).Methods("GET")
-	itemsRouter.HandleFunc("/{id}", updateItemHandler).Methods("PUT")
-	itemsRouter.HandleFunc("/{id}", deleteItemHandler).Methods("DELETE")
+	itemsRouter.Use(authenticate)
+	itemsRouter.Handle("", allow(rbac.ItemsRead, listItemsHandler)).Methods("GET")
+	itemsRouter.Handle("", allow(rbac.ItemsWrite, createItemHandler)).Methods("POST")
+	itemsRouter.Handle("/{id}", allow(rbac.ItemsRead, getItemHandler)).Methods("GET")
+	itemsRouter.Handle("/{id}", allow(rbac.ItemsWrite, updateItemHandler)).Methods("PUT")
+	itemsRouter.Handle("/{id}", allow(rbac.ItemsWrite, deleteItemHandler)).Methods("DELETE")
+
+	categoriesRouter := apiRouter.PathPrefix("/categories").Subrouter()
+	categoriesRouter.Use(authenticate)
+	categoriesRouter.Handle("", allow(rbac.CategoriesRead, listCategoriesHandler)).Methods("GET")
+	categoriesRouter.Handle("", allow(rbac.CategoriesWrite, createCategoryHandler)).Methods("POST")
+	categoriesRouter.Handle("/{id}", allow(rbac.CategoriesRead, getCategoryHandler)).Methods("GET")
+	categoriesRouter.Handle("/{id}", allow(rbac.CategoriesWrite, updateCategoryHandler)).Methods("PUT")
+	categoriesRouter.Handle("/{id}", allow(rbac.CategoriesWrite, deleteCategoryHandler)).Methods("DELETE")
+	categoriesRouter.Handle("/{id}/items", allow(rbac.ItemsRead, getCategoryItemsHandler)).Methods("GET")
+
+	itemsRouter.Handle("/{id}/tags/{tagId}", allow(rbac.ItemsWrite, attachItemTagHandler)).Methods("POST")
+	itemsRouter.Handle("/{id}/tags/{tagId}", allow(rbac.ItemsWrite, detachItemTagHandler)).Methods("DELETE")
 
 	tagsRouter := apiRouter.PathPrefix("/tags").Subrouter()
-	tagsRouter.Use(authMiddleware)
-	tagsRouter.HandleFunc("", createTagHandler).Methods("POST")
-	tagsRouter.HandleFunc("/{id}/items", getTagItemsHandler).Methods("GET")
-
-	apiRouter.HandleFunc("/search", searchHandler).Methods("GET")
-	apiRouter.HandleFunc("/analytics", getAnalyticsHandler).Methods("GET")
-	apiRouter.HandleFunc("/analytics/refresh", refreshAnalyticsHandler).Methods("POST")
-	apiRouter.HandleFunc("/audit-logs", getAuditLogsHandler).Methods("GET")
-	apiRouter.HandleFunc("/images/upload", imageUploadHandler).Methods("POST")
+	tagsRouter.Use(authenticate)
+	tagsRouter.Handle("", allow(rbac.TagsRead, listTagsHandler)).Methods("GET")
+	tagsRouter.Handle("", allow(rbac.TagsWrite, createTagHandler)).Methods("POST")
+	tagsRouter.Handle("/{id}", allow(rbac.TagsRead, getTagHandler)).Methods("GET")
+	tagsRouter.Handle("/{id}", allow(rbac.TagsWrite, updateTagHandler)).Methods("PUT")
+	tagsRouter.Handle("/{id}", allow(rbac.TagsWrite, deleteTagHandler)).Methods("DELETE")
+	tagsRouter.Handle("/{id}/items", allow(rbac.ItemsRead, getTagItemsHandler)).Methods("GET")
+
+	rolesRouter := apiRouter.PathPrefix("/roles").Subrouter()
+	rolesRouter.Use(authenticate)
+	rolesRouter.Handle("", allow(rbac.RolesManage, listRolesHandler)).Methods("GET")
+	rolesRouter.Handle("", allow(rbac.RolesManage, createRoleHandler)).Methods("POST")
+	rolesRouter.Handle("/{name}", allow(rbac.RolesManage, getRoleHandler)).Methods("GET")
+	rolesRouter.Handle("/{name}", allow(rbac.RolesManage, updateRoleHandler)).Methods("PUT")
+	rolesRouter.Handle("/{name}", allow(rbac.RolesManage, deleteRoleHandler)).Methods("DELETE")
+
+	apiRouter.Handle("/search", authenticate(allow(rbac.ItemsRead, searchHandler))).Methods("GET")
+	apiRouter.Handle("/analytics", authenticate(allow(rbac.AnalyticsRead, getAnalyticsHandler))).Methods("GET")
+	apiRouter.Handle("/analytics/refresh", authenticate(allow(rbac.AnalyticsWrite, refreshAnalyticsHandler))).Methods("POST")
+	apiRouter.Handle("/lockouts", authenticate(allow(rbac.UsersManage, listLockoutsHandler))).Methods("GET")
+	apiRouter.Handle("/lockouts/{id}", authenticate(allow(rbac.UsersManage, clearLockoutHandler))).Methods("DELETE")
+	apiRouter.Handle("/audit-logs", authenticate(allow(rbac.AuditRead, getAuditLogsHandler))).Methods("GET")
+	apiRouter.Handle("/images/upload", authenticate(allow(rbac.ImagesWrite, imageUploadHandler))).Methods("POST")
 
 	router.PathPrefix("/dashboard/").HandlerFunc(DashboardHandler)
 }
 
+// allow wraps handler so that it is only reached by principals holding
+// permission. Routes using it must also be authenticated.
+func allow(permission string, handler http.HandlerFunc) http.Handler {
+	return middleware.RequirePermission(permission)(handler)
+}
+
+// sessionOnly wraps handler so that it cannot be reached with an API key.
+func sessionOnly(handler http.HandlerFunc) http.Handler {
+	return middleware.SessionOnly(handler)
+}
+
 func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "./cmd/api/dashboard/pages/index.html")
 }
@@ -101,28 +184,73 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkLoginLockout(w, r, req.Username) {
+		return
+	}
+
 	user, err := st.GetUserByUsername(req.Username)
 	if err != nil {
+		crypto.VerifyPassword(req.Password, crypto.DummyHash)
+		recordLoginFailure(r, req.Username, "", "unknown username")
 		helper.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	if !crypto.VerifyPassword(req.Password, user.Password) {
+		recordLoginFailure(r, req.Username, user.ID, "wrong password")
 		helper.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
-	token, err := crypto.Encode(user.ID, cfg.Auth.Secret)
+	if user.Disabled() {
+		helper.RespondWithErrorCode(w, http.StatusForbidden, errCodeAccountDisabled, "Account is disabled")
+		return
+	}
+
+	if crypto.NeedsRehash(user.Password) {
+		upgradePasswordHash(user, req.Password)
+	}
+
+	if tf, err := st.GetTwoFactor(user.ID); err == nil && tf.Enabled() {
+		challenge, err := twoFactorChallenge(user)
+		if err != nil {
+			helper.RespondWithError(w, http.StatusInternalServerError, "Could not generate token")
+			return
+		}
+		helper.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication required", challenge)
+		return
+	}
+
+	clearLoginFailures(user.Username)
+
+	session, err := startSession(r, user)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create session")
+		return
+	}
+
+	tokens, err := issueTokens(user, session.ID, "")
 	if err != nil {
 		helper.RespondWithError(w, http.StatusInternalServerError, "Could not generate token")
 		return
 	}
 
-	helper.RespondWithSuccess(w, http.StatusOK, "Login successful", map[string]string{
-		"token":    token,
-		"user_id":  user.ID,
-		"username": user.Username,
-	})
+	helper.RespondWithSuccess(w, http.StatusOK, "Login successful", tokens)
+}
+
+// upgradePasswordHash replaces a legacy or outdated password hash after the
+// user has proven the password. Failure only leaves the old hash in place.
+func upgradePasswordHash(user storage.User, password string) {
+	passwordHash, err := crypto.HashPassword(password)
+	if err != nil {
+		log.Printf("Could not rehash password for user %s: %v", user.ID, err)
+		return
+	}
+
+	user.Password = passwordHash
+	if err := st.UpdateUser(user); err != nil {
+		log.Printf("Could not upgrade password hash for user %s: %v", user.ID, err)
+	}
 }
 
 func registerHandler(w http.ResponseWriter, r *http.Request) {
@@ -132,16 +260,14 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !helper.ValidateUsername(req.Username) {
-		helper.RespondWithError(w, http.StatusBadRequest, "Invalid username format")
+	if !checkUsername(w, req.Username) {
 		return
 	}
 	if !helper.ValidateEmail(req.Email) {
 		helper.RespondWithError(w, http.StatusBadRequest, "Invalid email format")
 		return
 	}
-	if !helper.ValidatePassword(req.Password) {
-		helper.RespondWithError(w, http.StatusBadRequest, "Password must be at least 8 characters")
+	if !checkPassword(w, req.Password, req.Username, req.Email) {
 		return
 	}
 
@@ -150,13 +276,25 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		helper.RespondWithError(w, http.StatusConflict, "Username already taken")
 		return
 	}
+	if emailTaken(req.Email, "") {
+		helper.RespondWithError(w, http.StatusConflict, "Email already in use")
+		return
+	}
+
+	passwordHash, err := crypto.HashPassword(req.Password)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create user")
+		return
+	}
 
 	user := storage.User{
-		ID:        uuid.New().String(),
-		Username:  req.Username,
-		Password:  crypto.HashPassword(req.Password),
-		Email:     req.Email,
-		CreatedAt: time.Now(),
+		ID:              uuid.New().String(),
+		Username:        req.Username,
+		Password:        passwordHash,
+		Email:           req.Email,
+		Role:            storage.RoleUser,
+		CreatedAt:       time.Now(),
+		MustVerifyEmail: mail != nil,
 	}
 
 	if err := st.CreateUser(user); err != nil {
@@ -164,19 +302,39 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	helper.RespondWithSuccess(w, http.StatusCreated, "User created successfully", map[string]string{
-		"user_id": user.ID,
+	if user.MustVerifyEmail {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("Could not send verification email to user %s: %v", user.ID, err)
+		}
+	}
+
+	helper.RespondWithSuccess(w, http.StatusCreated, "User created successfully", map[string]interface{}{
+		"user_id":                     user.ID,
+		"email_verification_required": user.MustVerifyEmail,
 	})
 }
 
 func listUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users := st.ListUsers()
+	if isCursorRequest(r) {
+		params, err := cursorFromQuery(r, "users", userFilters)
+		if err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-	for i := range users {
-		users[i].Password = ""
+		page := st.GetUsersByCursor(params)
+		helper.RespondWithSuccess(w, http.StatusOK, "Users retrieved", cursorResult("users", params, page))
+		return
+	}
+
+	params, err := paginationFromQuery(r, storage.UserSortFields, userFilters)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	helper.RespondWithSuccess(w, http.StatusOK, "Users retrieved", users)
+	result := st.GetUsersPaginated(params)
+	helper.RespondWithSuccess(w, http.StatusOK, "Users retrieved", result)
 }
 
 func getUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -195,8 +353,26 @@ func getUserHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func listItemsHandler(w http.ResponseWriter, r *http.Request) {
-	items := st.ListItems()
-	helper.RespondWithSuccess(w, http.StatusOK, "Items retrieved", items)
+	if isCursorRequest(r) {
+		params, err := cursorFromQuery(r, "items", itemFilters)
+		if err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		page := st.GetItemsByCursor(params)
+		helper.RespondWithSuccess(w, http.StatusOK, "Items retrieved", cursorResult("items", params, page))
+		return
+	}
+
+	params, err := paginationFromQuery(r, storage.ItemSortFields, itemFilters)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := st.GetItemsPaginated(params)
+	helper.RespondWithSuccess(w, http.StatusOK, "Items retrieved", result)
 }
 
 func getItemHandler(w http.ResponseWriter, r *http.Request) {
@@ -224,13 +400,21 @@ func createItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
+	if req.CategoryID != "" {
+		if _, err := st.GetCategory(req.CategoryID); err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, "Category not found")
+			return
+		}
+	}
+
+	userID, _ := helper.GetUserFromContext(r.Context())
 
 	item := storage.Item{
 		ID:          uuid.New().String(),
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
+		CategoryID:  req.CategoryID,
 		CreatedAt:   time.Now(),
 		CreatedBy:   userID,
 	}
@@ -264,9 +448,14 @@ func updateItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
+	if req.CategoryID != "" {
+		if _, err := st.GetCategory(req.CategoryID); err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, "Category not found")
+			return
+		}
+	}
 
-	if existingItem.CreatedBy != userID {
+	if !canModify(r, existingItem.CreatedBy, rbac.ItemsManage) {
 		helper.RespondWithError(w, http.StatusForbidden, "You don't have permission to update this item")
 		return
 	}
@@ -274,6 +463,7 @@ func updateItemHandler(w http.ResponseWriter, r *http.Request) {
 	existingItem.Name = req.Name
 	existingItem.Description = req.Description
 	existingItem.Price = req.Price
+	existingItem.CategoryID = req.CategoryID
 
 	if err := st.UpdateItem(existingItem); err != nil {
 		helper.RespondWithError(w, http.StatusInternalServerError, "Could not update item")
@@ -293,10 +483,7 @@ func deleteItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
-	userRole := r.Context().Value("userRole").(string)
-
-	if existingItem.CreatedBy != userID && userRole != storage.RoleAdmin {
+	if !canModify(r, existingItem.CreatedBy, rbac.ItemsManage) {
 		helper.RespondWithError(w, http.StatusForbidden, "You don't have permission to delete this item")
 		return
 	}
@@ -309,48 +496,6 @@ func deleteItemHandler(w http.ResponseWriter, r *http.Request) {
 	helper.RespondWithSuccess(w, http.StatusOK, "Item deleted", nil)
 }
 
-func createTagHandler(w http.ResponseWriter, r *http.Request) {
-	var req TagRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
-		return
-	}
-
-	if req.Name == "" {
-		helper.RespondWithError(w, http.StatusBadRequest, "Tag name is required")
-		return
-	}
-
-	userID := r.Context().Value("userID").(string)
-
-	tag := storage.Tag{
-		ID:        uuid.New().String(),
-		Name:      req.Name,
-		CreatedAt: time.Now(),
-		CreatedBy: userID,
-	}
-
-	if err := st.CreateTag(tag); err != nil {
-		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create tag")
-		return
-	}
-
-	helper.RespondWithSuccess(w, http.StatusCreated, "Tag created", tag)
-}
-
-func getTagItemsHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	if _, err := st.GetTag(id); err != nil {
-		helper.RespondWithError(w, http.StatusNotFound, "Tag not found")
-		return
-	}
-
-	items := st.GetItemsByTag(id)
-	helper.RespondWithSuccess(w, http.StatusOK, "Tag items retrieved", items)
-}
-
 func searchHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	if query == "" {
@@ -359,15 +504,23 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	entityType := r.URL.Query().Get("type")
+	principal, _ := helper.PrincipalFromContext(r.Context())
 	var results interface{}
 
 	switch entityType {
 	case "users":
+		if !principal.Can(rbac.UsersRead) {
+			helper.RespondWithErrorCode(w, http.StatusForbidden, middleware.ErrCodeForbidden, "Missing permission: "+rbac.UsersRead)
+			return
+		}
 		results = st.SearchUsers(query)
 	case "items":
 		results = st.SearchItems(query)
 	default:
-		userResults := st.SearchUsers(query)
+		userResults := []storage.User{}
+		if principal.Can(rbac.UsersRead) {
+			userResults = st.SearchUsers(query)
+		}
 		itemResults := st.SearchItems(query)
 
 		results = map[string]interface{}{
@@ -385,12 +538,28 @@ func getAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func refreshAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
-	st.UpdateAnalytics()
+	if err := st.UpdateAnalytics(); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not refresh analytics")
+		return
+	}
+
 	analytics := st.GetAnalytics()
 	helper.RespondWithSuccess(w, http.StatusOK, "Analytics refreshed", analytics)
 }
 
 func getAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
+	if isCursorRequest(r) {
+		params, err := cursorFromQuery(r, "audit_logs", auditLogFilters)
+		if err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		page := st.GetAuditLogsByCursor(params)
+		helper.RespondWithSuccess(w, http.StatusOK, "Audit logs retrieved", cursorResult("audit_logs", params, page))
+		return
+	}
+
 	limitStr := r.URL.Query().Get("limit")
 	limit := 50
 
@@ -425,39 +594,3 @@ func imageUploadHandler(w http.ResponseWriter, r *http.Request) {
 		"image_url": "/api/images/items/" + filename,
 	})
 }
-
-func authMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-
-		if authHeader == "" {
-			helper.RespondWithError(w, http.StatusUnauthorized, "Authorization header required")
-			return
-		}
-
-		tokenParts := strings.Split(authHeader, "Bearer ")
-		if len(tokenParts) != 2 {
-			helper.RespondWithError(w, http.StatusUnauthorized, "Invalid token format")
-			return
-		}
-
-		token := tokenParts[1]
-
-		userID, err := crypto.Decode(token, cfg.Auth.Secret)
-		if err != nil {
-			helper.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
-
-		user, err := st.GetUser(userID)
-		if err != nil {
-			helper.RespondWithError(w, http.StatusUnauthorized, "User not found")
-			return
-		}
-
-		ctx := helper.SetUserContext(r.Context(), userID)
-		ctx = helper.SetUserRoleContext(ctx, user.Role)
-
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
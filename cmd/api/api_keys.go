@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// apiKeyPrefixLength is how much of a key is kept in APIKey.Prefix: the
+// fixed prefix and six random characters.
+const apiKeyPrefixLength = len(crypto.APIKeyPrefix) + 6
+
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	keys := st.ListUserAPIKeys(userID)
+	for i := range keys {
+		keys[i].Hash = ""
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "API keys retrieved", keys)
+}
+
+// createAPIKeyHandler mints an API key for the authenticated user. The key
+// can do no more than both its scopes and the user's role allow, and is only
+// returned in this response.
+func createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		helper.RespondWithError(w, http.StatusBadRequest, "Name must be 1-64 characters")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		helper.RespondWithError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	if err := rbac.Validate(req.Scopes); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		helper.RespondWithError(w, http.StatusBadRequest, "Expiry must be in the future")
+		return
+	}
+
+	secret, err := crypto.NewAPIKey()
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create API key")
+		return
+	}
+
+	key := storage.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    secret[:apiKeyPrefixLength],
+		Hash:      crypto.HashToken(secret),
+		Scopes:    req.Scopes,
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	if err := st.CreateAPIKey(key); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create API key")
+		return
+	}
+
+	recordAudit("create", "api_key", key.ID, userID, "created API key "+key.Name)
+
+	key.Hash = ""
+	helper.RespondWithSuccess(w, http.StatusCreated, "API key created; it will not be shown again", map[string]interface{}{
+		"key":     secret,
+		"api_key": key,
+	})
+}
+
+func revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	key, err := st.GetAPIKey(id)
+	if err != nil || key.UserID != userID {
+		helper.RespondWithError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	if err := st.RevokeAPIKey(id); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not revoke API key")
+		return
+	}
+
+	recordAudit("revoke", "api_key", id, userID, "revoked API key "+key.Name)
+	helper.RespondWithSuccess(w, http.StatusOK, "API key revoked", nil)
+}
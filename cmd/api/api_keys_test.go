@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/middleware"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+func TestAPIKeyScopes(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.createUser("alice", storage.RoleUser)
+	token := srv.login("alice").field("token")
+
+	created := srv.call("POST", "/api/me/api-keys", token, APIKeyRequest{
+		Name:   "reporting",
+		Scopes: []string{rbac.ItemsRead, rbac.AuditRead},
+	})
+	expect(t, "create API key", created, http.StatusCreated)
+	key := created.field("key")
+	withKey := func(method, path string, body interface{}) testResponse {
+		return srv.do(method, path, body, http.Header{middleware.APIKeyHeader: {key}})
+	}
+
+	expect(t, "in scope", withKey("GET", "/api/items", nil), http.StatusOK)
+	expect(t, "as bearer token", srv.call("GET", "/api/items", key, nil), http.StatusOK)
+	expect(t, "out of scope", withKey("POST", "/api/items", ItemRequest{Name: "Lamp", Price: 10}), http.StatusForbidden)
+	expect(t, "allowed by the user's role only", withKey("GET", "/api/categories", nil), http.StatusForbidden)
+	// A scope does not grant what the user's role does not.
+	expect(t, "allowed by the scope only", withKey("GET", "/api/audit-logs", nil), http.StatusForbidden)
+
+	apiKey, ok := created.Data.(map[string]interface{})["api_key"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no api_key in %v", created.Data)
+	}
+	expect(t, "revoke API key", srv.call("DELETE", "/api/me/api-keys/"+apiKey["id"].(string), token, nil), http.StatusOK)
+	expect(t, "revoked key", withKey("GET", "/api/items", nil), http.StatusUnauthorized)
+}
+
+func TestAPIKeysCannotUseSessionOnlyRoutes(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.createUser("alice", storage.RoleUser)
+	token := srv.login("alice").field("token")
+
+	created := srv.call("POST", "/api/me/api-keys", token, APIKeyRequest{Name: "everything", Scopes: []string{rbac.Wildcard}})
+	expect(t, "create API key", created, http.StatusCreated)
+	key := created.field("key")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"change password", "POST", "/api/me/password", map[string]string{"current_password": testPassword, "new_password": "quiet-meadow-77"}},
+		{"update profile", "PUT", "/api/me", map[string]string{"email": "mallory@example.com"}},
+		{"list API keys", "GET", "/api/me/api-keys", nil},
+		{"create API key", "POST", "/api/me/api-keys", APIKeyRequest{Name: "another", Scopes: []string{rbac.Wildcard}}},
+		{"set up 2fa", "POST", "/api/me/2fa/setup", nil},
+		{"list sessions", "GET", "/api/auth/sessions", nil},
+		{"log out everywhere", "POST", "/api/auth/logout-all", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := srv.call(tt.method, tt.path, key, tt.body)
+			expect(t, tt.name, res, http.StatusForbidden)
+			if res.Code != middleware.ErrCodeForbidden {
+				t.Errorf("code %q, want %q", res.Code, middleware.ErrCodeForbidden)
+			}
+		})
+	}
+
+	expect(t, "profile", srv.call("GET", "/api/me", key, nil), http.StatusOK)
+	expect(t, "password unchanged", srv.call("POST", "/api/auth/login", "", UserLoginRequest{Username: "alice", Password: testPassword}), http.StatusOK)
+}
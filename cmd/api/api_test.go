@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/config"
+	"github.com/C0d3-5t3w/aServ/internal/mailer"
+	"github.com/C0d3-5t3w/aServ/internal/policy"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// testPassword is the password of every user created with createUser.
+const testPassword = "violet-harbor-42"
+
+// testServer serves the API routes over a fresh JSON store. The handlers
+// share package state, so tests using it must not run in parallel.
+type testServer struct {
+	*httptest.Server
+	t *testing.T
+	// sent receives every message the handlers mail.
+	sent chan mailer.Message
+}
+
+// captureMailer hands every message to a channel instead of sending it.
+type captureMailer chan mailer.Message
+
+func (c captureMailer) Send(msg mailer.Message) error {
+	c <- msg
+	return nil
+}
+
+// newTestServer starts a server with the default configuration, changed by
+// configure when it is not nil.
+func newTestServer(t *testing.T, configure func(*config.Config)) *testServer {
+	t.Helper()
+
+	dir := t.TempDir()
+	c := config.LoadConfig(filepath.Join(dir, "missing.yaml"))
+	c.Storage.Path = filepath.Join(dir, "storage.json")
+	c.Mail.Driver = "capture"
+	if configure != nil {
+		configure(c)
+	}
+
+	store, err := storage.NewStorage(storage.Options{Path: c.Storage.Path, Backend: c.Storage.Driver})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := rbac.SyncRoles(store, c.Roles); err != nil {
+		t.Fatalf("SyncRoles: %v", err)
+	}
+
+	rules, err := policy.New(c)
+	if err != nil {
+		t.Fatalf("policy.New: %v", err)
+	}
+	keys, err := NewKeyring(c)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	sent := make(captureMailer, 16)
+	router := mux.NewRouter().StrictSlash(true)
+	RegisterRoutes(router, c, store, keys, sent, rules)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return &testServer{Server: server, t: t, sent: sent}
+}
+
+// createUser stores a user with testPassword and the given role.
+func (s *testServer) createUser(username, role string) storage.User {
+	s.t.Helper()
+
+	passwordHash, err := crypto.HashPassword(testPassword)
+	if err != nil {
+		s.t.Fatalf("HashPassword: %v", err)
+	}
+
+	now := time.Now()
+	user := storage.User{
+		ID:        uuid.New().String(),
+		Username:  username,
+		Password:  passwordHash,
+		Email:     username + "@example.com",
+		Role:      role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := st.CreateUser(user); err != nil {
+		s.t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// testResponse is a decoded API response.
+type testResponse struct {
+	Status int
+	helper.APIResponse
+}
+
+// field returns the string field name of the response data.
+func (r testResponse) field(name string) string {
+	data, _ := r.Data.(map[string]interface{})
+	value, _ := data[name].(string)
+	return value
+}
+
+// do sends body as JSON with the given request headers and decodes the
+// response.
+func (s *testServer) do(method, path string, body interface{}, header http.Header) testResponse {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		s.t.Fatalf("NewRequest: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+
+	decoded := testResponse{Status: res.StatusCode}
+	if err := json.NewDecoder(res.Body).Decode(&decoded.APIResponse); err != nil {
+		s.t.Fatalf("%s %s: decoding response: %v", method, path, err)
+	}
+	return decoded
+}
+
+// call is do with the access token, if any, as a bearer token.
+func (s *testServer) call(method, path, token string, body interface{}) testResponse {
+	s.t.Helper()
+
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return s.do(method, path, body, header)
+}
+
+// login logs username in with testPassword and returns the response data.
+func (s *testServer) login(username string) testResponse {
+	s.t.Helper()
+
+	res := s.call("POST", "/api/auth/login", "", UserLoginRequest{Username: username, Password: testPassword})
+	if res.Status != http.StatusOK {
+		s.t.Fatalf("login %s: status %d: %s", username, res.Status, res.Error)
+	}
+	return res
+}
+
+// expect fails the test unless res has status want.
+func expect(t *testing.T, what string, res testResponse, want int) {
+	t.Helper()
+
+	if res.Status != want {
+		t.Fatalf("%s: status %d (%s), want %d", what, res.Status, res.Error, want)
+	}
+}
+
+// legacyTestPasswordHash is testPassword as stored before argon2id: an
+// unsalted SHA-256 hex digest.
+const legacyTestPasswordHash = "b348ededdf544fae783e7aed5f699c4451f780c77e0133cca2b0ff18b35d8b9a"
+
+func TestLoginUpgradesLegacyPasswordHash(t *testing.T) {
+	srv := newTestServer(t, nil)
+	alice := srv.createUser("alice", storage.RoleUser)
+	alice.Password = legacyTestPasswordHash
+	if err := st.UpdateUser(alice); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	wrong := srv.call("POST", "/api/auth/login", "", UserLoginRequest{Username: "alice", Password: legacyTestPasswordHash})
+	expect(t, "login with the digest as password", wrong, http.StatusUnauthorized)
+	if user, _ := st.GetUser(alice.ID); user.Password != legacyTestPasswordHash {
+		t.Fatalf("a failed login replaced the legacy hash with %q", user.Password)
+	}
+
+	srv.login("alice")
+	user, err := st.GetUser(alice.ID)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if !strings.HasPrefix(user.Password, "$argon2id$") || crypto.NeedsRehash(user.Password) {
+		t.Fatalf("hash after login = %q, want a current argon2id hash", user.Password)
+	}
+	srv.login("alice")
+}
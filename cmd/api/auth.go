@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	errCodeRefreshInvalid = "refresh_token_invalid"
+	errCodeRefreshReused  = "refresh_token_reused"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshHandler exchanges a refresh token for a new access and refresh token
+// pair. Each refresh token works once: presenting one that was already
+// rotated means it has been copied, so its whole family is revoked and the
+// user has to log in again.
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	current, err := st.GetRefreshToken(crypto.HashToken(req.RefreshToken))
+	if err != nil || current.RevokedAt != nil || !time.Now().Before(current.ExpiresAt) {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, errCodeRefreshInvalid, "Invalid refresh token")
+		return
+	}
+
+	if current.UsedAt != nil {
+		revokeReusedFamily(current)
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, errCodeRefreshReused, "Refresh token has already been used")
+		return
+	}
+
+	user, err := st.GetUser(current.UserID)
+	if err != nil || user.Disabled() {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, errCodeRefreshInvalid, "Invalid refresh token")
+		return
+	}
+
+	tokens, err := issueTokens(user, current.FamilyID, current.ID)
+	if errors.Is(err, storage.ErrTokenReused) {
+		revokeReusedFamily(current)
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, errCodeRefreshReused, "Refresh token has already been used")
+		return
+	}
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Token refreshed", tokens)
+}
+
+// issueTokens creates an access token for user and a refresh token in
+// familyID, which is also the ID of the session both belong to. With rotate
+// set the refresh token replaces that stored token and the session is
+// extended; otherwise it starts the family.
+func issueTokens(user storage.User, familyID string, rotate string) (map[string]interface{}, error) {
+	claims := crypto.NewClaims(user.ID, user.Role, accessTokenTTL())
+	claims.SessionID = familyID
+	accessToken, err := crypto.IssueToken(claims, keyring)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := crypto.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stored := storage.RefreshToken{
+		ID:        crypto.HashToken(refreshToken),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(refreshTokenTTL()),
+	}
+
+	if rotate != "" {
+		err = st.RotateRefreshToken(rotate, stored)
+	} else {
+		err = st.CreateRefreshToken(stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rotate != "" {
+		st.TouchSession(familyID, now)
+		st.ExtendSession(familyID, stored.ExpiresAt)
+	}
+
+	return map[string]interface{}{
+		"token":                accessToken,
+		"token_type":           "Bearer",
+		"expires_at":           claims.ExpiresAtTime(),
+		"refresh_token":        refreshToken,
+		"refresh_expires_at":   stored.ExpiresAt,
+		"user_id":              user.ID,
+		"username":             user.Username,
+		"must_change_password": user.MustChangePassword,
+		"must_verify_email":    user.MustVerifyEmail,
+	}, nil
+}
+
+// logoutHandler revokes the access token used for the request and, when the
+// body names the matching refresh token, the refresh token family as well.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := helper.PrincipalFromContext(r.Context())
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	err := st.PutRevocation(storage.Revocation{
+		ID:        storage.TokenRevocationID(principal.TokenID),
+		UserID:    principal.UserID,
+		TokenID:   principal.TokenID,
+		RevokedAt: time.Now(),
+		ExpiresAt: principal.ExpiresAt,
+	})
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not log out")
+		return
+	}
+
+	if principal.SessionID != "" {
+		if err := endSession(principal.SessionID); err != nil {
+			helper.RespondWithError(w, http.StatusInternalServerError, "Could not log out")
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		token, err := st.GetRefreshToken(crypto.HashToken(req.RefreshToken))
+		if err == nil && token.UserID == principal.UserID {
+			if err := st.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+				helper.RespondWithError(w, http.StatusInternalServerError, "Could not log out")
+				return
+			}
+		}
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Logged out", nil)
+}
+
+// logoutAllHandler ends every session of the authenticated user, including
+// the one making the request.
+func logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	if err := revokeUserSessions(userID); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not log out")
+		return
+	}
+
+	recordAudit("logout_all", "user", userID, userID, "revoked all sessions")
+	helper.RespondWithSuccess(w, http.StatusOK, "Logged out of all sessions", nil)
+}
+
+// revokeUserSessionsHandler lets an admin end every session of another user.
+func revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := st.GetUser(id); err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := revokeUserSessions(id); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions")
+		return
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("revoke_sessions", "user", id, adminID, "revoked all sessions")
+	helper.RespondWithSuccess(w, http.StatusOK, "Sessions revoked", nil)
+}
+
+// revokeUserSessions rejects every access token issued to userID so far and
+// revokes all of the user's refresh tokens. The revocation entry is kept until
+// the last access token it covers would have expired.
+func revokeUserSessions(userID string) error {
+	now := time.Now()
+
+	err := st.PutRevocation(storage.Revocation{
+		ID:        storage.UserRevocationID(userID),
+		UserID:    userID,
+		RevokedAt: now,
+		ExpiresAt: now.Add(accessTokenTTL()),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := st.RevokeUserSessions(userID); err != nil {
+		return err
+	}
+	return st.RevokeUserRefreshTokens(userID)
+}
+
+func revokeReusedFamily(token storage.RefreshToken) {
+	if err := st.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+		log.Printf("Could not revoke refresh token family %s: %v", token.FamilyID, err)
+	}
+
+	recordAudit("refresh_token_reuse", "user", token.UserID, token.UserID, "revoked token family "+token.FamilyID)
+}
+
+// recordAudit adds an audit log entry for a security-relevant event when
+// auditing is enabled.
+func recordAudit(action, entity, entityID, userID, details string) {
+	if !cfg.Features.Audit {
+		return
+	}
+	writeAudit(action, entity, entityID, userID, details)
+}
+
+// writeAudit adds an audit log entry whether or not auditing is enabled, for
+// events that must always leave a trace.
+func writeAudit(action, entity, entityID, userID, details string) {
+	err := st.CreateAuditLog(storage.AuditLog{
+		ID:        uuid.New().String(),
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		UserID:    userID,
+		Timestamp: time.Now(),
+		Details:   details,
+	})
+	if err != nil {
+		log.Printf("Could not record audit event %s: %v", action, err)
+	}
+}
+
+// accessTokenTTL is how long an access token stays valid, from auth.expire_hrs.
+func accessTokenTTL() time.Duration {
+	if cfg.Auth.ExpireHrs <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(cfg.Auth.ExpireHrs) * time.Hour
+}
+
+// refreshTokenTTL is how long a refresh token stays valid, from
+// auth.refresh_expire_hrs. Every rotation starts a new period.
+func refreshTokenTTL() time.Duration {
+	if cfg.Auth.RefreshExpireHrs <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(cfg.Auth.RefreshExpireHrs) * time.Hour
+}
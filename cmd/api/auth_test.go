@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/cmd/api/middleware"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.createUser("alice", storage.RoleUser)
+	refresh := func(token string) testResponse {
+		return srv.call("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: token})
+	}
+
+	first := srv.login("alice").field("refresh_token")
+	rotated := refresh(first)
+	expect(t, "refresh", rotated, http.StatusOK)
+	second := rotated.field("refresh_token")
+	if second == "" || second == first {
+		t.Fatalf("refresh returned refresh token %q after %q", second, first)
+	}
+
+	reused := refresh(first)
+	expect(t, "reusing the rotated token", reused, http.StatusUnauthorized)
+	if reused.Code != errCodeRefreshReused {
+		t.Errorf("reusing the rotated token: code %q, want %q", reused.Code, errCodeRefreshReused)
+	}
+
+	// The token issued in the rotation belongs to the same family, so it
+	// may be in the hands of whoever copied the first one.
+	sibling := refresh(second)
+	expect(t, "refreshing with the sibling token", sibling, http.StatusUnauthorized)
+	if sibling.Code != errCodeRefreshInvalid {
+		t.Errorf("refreshing with the sibling token: code %q, want %q", sibling.Code, errCodeRefreshInvalid)
+	}
+
+	// Other logins are not affected.
+	other := srv.login("alice").field("refresh_token")
+	expect(t, "refreshing another session", refresh(other), http.StatusOK)
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	srv := newTestServer(t, nil)
+	alice := srv.createUser("alice", storage.RoleUser)
+
+	// A token without a session is only rejected through the revocation
+	// list, not by the session check.
+	claims := crypto.NewClaims(alice.ID, alice.Role, time.Hour)
+	token, err := crypto.IssueToken(claims, keyring)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	other := srv.login("alice").field("token")
+
+	expect(t, "before logout", srv.call("GET", "/api/me", token, nil), http.StatusOK)
+	expect(t, "logout", srv.call("POST", "/api/auth/logout", token, nil), http.StatusOK)
+
+	if _, err := st.GetRevocation(storage.TokenRevocationID(claims.ID)); err != nil {
+		t.Fatalf("no revocation for the logged out token: %v", err)
+	}
+	res := srv.call("GET", "/api/me", token, nil)
+	expect(t, "after logout", res, http.StatusUnauthorized)
+	if res.Code != middleware.ErrCodeTokenRevoked {
+		t.Errorf("after logout: code %q, want %q", res.Code, middleware.ErrCodeTokenRevoked)
+	}
+	expect(t, "other session", srv.call("GET", "/api/me", other, nil), http.StatusOK)
+
+	expect(t, "logout-all", srv.call("POST", "/api/auth/logout-all", other, nil), http.StatusOK)
+	expect(t, "after logout-all", srv.call("GET", "/api/me", other, nil), http.StatusUnauthorized)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/internal/config"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/google/uuid"
+)
+
+// BootstrapAdmin creates the admin account described by cfg.Admin when store
+// has no users yet, so that a fresh installation can be logged into. The
+// configured password is only a first password: the admin must change it on
+// first login. It reports whether an account was created.
+func BootstrapAdmin(cfg *config.Config, store storage.Store) (bool, error) {
+	if len(store.ListUsers()) > 0 {
+		return false, nil
+	}
+
+	admin := cfg.Admin
+	if admin.DefaultUsername == "" || admin.DefaultPassword == "" {
+		return false, errors.New("admin.default_username and admin.default_password are required to create the first admin")
+	}
+
+	passwordHash, err := crypto.HashPassword(admin.DefaultPassword)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	user := storage.User{
+		ID:                 uuid.New().String(),
+		Username:           admin.DefaultUsername,
+		Password:           passwordHash,
+		Email:              admin.DefaultEmail,
+		Role:               storage.RoleAdmin,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		MustChangePassword: true,
+	}
+
+	if err := store.CreateUser(user); err != nil {
+		return false, err
+	}
+	return true, nil
+}
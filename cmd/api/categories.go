@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type CategoryRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func listCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	categories := st.ListCategories()
+	helper.RespondWithSuccess(w, http.StatusOK, "Categories retrieved", categories)
+}
+
+func getCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	category, err := st.GetCategory(id)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Category retrieved", category)
+}
+
+func createCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Category name is required")
+		return
+	}
+
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	category := storage.Category{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		CreatedBy:   userID,
+	}
+
+	err := st.CreateCategory(category)
+	if errors.Is(err, storage.ErrCategoryNameTaken) {
+		helper.RespondWithError(w, http.StatusConflict, "Category name already exists")
+		return
+	}
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create category")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusCreated, "Category created", category)
+}
+
+func updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	existingCategory, err := st.GetCategory(id)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Category name is required")
+		return
+	}
+
+	if !canModify(r, existingCategory.CreatedBy, rbac.CategoriesManage) {
+		helper.RespondWithError(w, http.StatusForbidden, "You don't have permission to update this category")
+		return
+	}
+
+	existingCategory.Name = req.Name
+	existingCategory.Description = req.Description
+
+	err = st.UpdateCategory(existingCategory)
+	if errors.Is(err, storage.ErrCategoryNameTaken) {
+		helper.RespondWithError(w, http.StatusConflict, "Category name already exists")
+		return
+	}
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not update category")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Category updated", existingCategory)
+}
+
+// deleteCategoryHandler removes a category. Items in it are moved to the
+// category given by the reassign_to query parameter, or left uncategorised
+// when it is omitted.
+func deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	existingCategory, err := st.GetCategory(id)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	if !canModify(r, existingCategory.CreatedBy, rbac.CategoriesManage) {
+		helper.RespondWithError(w, http.StatusForbidden, "You don't have permission to delete this category")
+		return
+	}
+
+	reassignTo := r.URL.Query().Get("reassign_to")
+	if reassignTo != "" {
+		if reassignTo == id {
+			helper.RespondWithError(w, http.StatusBadRequest, "Cannot reassign items to the category being deleted")
+			return
+		}
+		if _, err := st.GetCategory(reassignTo); err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, "Reassignment category not found")
+			return
+		}
+	}
+
+	affected := len(st.GetItemsByCategory(id))
+
+	if err := st.DeleteCategory(id, reassignTo); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not delete category")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Category deleted", map[string]interface{}{
+		"items_affected": affected,
+		"reassigned_to":  reassignTo,
+	})
+}
+
+func getCategoryItemsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if _, err := st.GetCategory(id); err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	items := st.GetItemsByCategory(id)
+	helper.RespondWithSuccess(w, http.StatusOK, "Category items retrieved", items)
+}
+
+// canModify reports whether the authenticated user owns a resource created by
+// ownerID or holds managePermission, which covers everyone's resources.
+func canModify(r *http.Request, ownerID string, managePermission string) bool {
+	principal, _ := helper.PrincipalFromContext(r.Context())
+	return principal.UserID == ownerID || principal.Can(managePermission)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+func TestCategoryNameConflict(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.createUser("alice", storage.RoleUser)
+	token := srv.login("alice").field("token")
+
+	created := srv.call("POST", "/api/categories", token, CategoryRequest{Name: "Lamps"})
+	expect(t, "create Lamps", created, http.StatusCreated)
+	books := srv.call("POST", "/api/categories", token, CategoryRequest{Name: "Books"})
+	expect(t, "create Books", books, http.StatusCreated)
+
+	expect(t, "create lamps", srv.call("POST", "/api/categories", token, CategoryRequest{Name: " lamps "}), http.StatusConflict)
+	expect(t, "rename Books to LAMPS", srv.call("PUT", "/api/categories/"+books.field("id"), token, CategoryRequest{Name: "LAMPS"}), http.StatusConflict)
+	expect(t, "rename Lamps to LAMPS", srv.call("PUT", "/api/categories/"+created.field("id"), token, CategoryRequest{Name: "LAMPS"}), http.StatusOK)
+}
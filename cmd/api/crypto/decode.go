@@ -1,44 +0,0 @@
-package crypto
-
-import (
-	"crypto/aes"
-	"crypto/cipher"
-	"encoding/base64"
-	"errors"
-)
-
-func Decode(encodedData string, secretKey string) (string, error) {
-	key := createKey(secretKey)
-
-	ciphertext, err := base64.StdEncoding.DecodeString(encodedData)
-	if err != nil {
-		return "", err
-	}
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	if len(ciphertext) < gcm.NonceSize() {
-		return "", errors.New("ciphertext too short")
-	}
-
-	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", err
-	}
-
-	return string(plaintext), nil
-}
-
-func VerifyPassword(password string, hash string) bool {
-	passwordHash := HashPassword(password)
-	return passwordHash == hash
-}
@@ -0,0 +1,158 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrTokenSignature   = errors.New("token signature is invalid")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+)
+
+// Claims is the payload of an access token, using the registered JWT claim
+// names so that other services can verify tokens with any JWT library.
+type Claims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+	SessionID string `json:"sid,omitempty"`
+	// Scope is a space-separated list of scopes the token is limited to.
+	Scope string `json:"scope,omitempty"`
+	// Purpose marks tokens that are not access tokens, such as the
+	// PurposeTwoFactor challenge issued between a correct password and the
+	// second factor. AuthMiddleware refuses every token that has one.
+	Purpose string `json:"purpose,omitempty"`
+	// IssuedAtMillis is iat in milliseconds, so that revoking a user's
+	// tokens spares those issued later in the same second.
+	IssuedAtMillis int64 `json:"iat_ms,omitempty"`
+}
+
+// PurposeTwoFactor is the Purpose of a login challenge token, which can only
+// be exchanged for an access token at /api/auth/2fa/verify.
+const PurposeTwoFactor = "2fa"
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ"`
+}
+
+// NewClaims returns claims for subject valid from now until ttl has passed,
+// with a fresh token ID.
+func NewClaims(subject, role string, ttl time.Duration) Claims {
+	now := time.Now()
+	return Claims{
+		Subject:        subject,
+		Role:           role,
+		IssuedAt:       now.Unix(),
+		NotBefore:      now.Unix(),
+		ExpiresAt:      now.Add(ttl).Unix(),
+		ID:             uuid.New().String(),
+		IssuedAtMillis: now.UnixMilli(),
+	}
+}
+
+// IssuedBefore reports whether the token was issued no later than t. Tokens
+// without iat_ms are compared by the second and count as issued before any
+// time in their second.
+func (c Claims) IssuedBefore(t time.Time) bool {
+	if c.IssuedAtMillis == 0 {
+		return c.IssuedAt <= t.Unix()
+	}
+	return c.IssuedAtMillis <= t.UnixMilli()
+}
+
+// ExpiresAtTime returns the exp claim as a time.
+func (c Claims) ExpiresAtTime() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// IssueToken encodes claims as a compact HS256 JWT signed with the keyring's
+// signing key, whose ID goes in the kid header.
+func IssueToken(claims Claims, keyring *Keyring) (string, error) {
+	key := keyring.Signing()
+
+	header, err := json.Marshal(jwtHeader{Algorithm: "HS256", KeyID: key.ID, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac(signingInput, key.Secret)), nil
+}
+
+// ParseToken verifies an HS256 JWT with the keyring key named by its kid
+// header and checks its validity window. Tokens without a kid are verified
+// with DefaultKeyID. The returned error is one of the ErrToken values.
+func ParseToken(token string, keyring *Keyring) (Claims, error) {
+	var claims Claims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrTokenMalformed
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, ErrTokenMalformed
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return claims, ErrTokenMalformed
+	}
+	// Only HS256 is accepted; in particular "none" must never verify.
+	if header.Algorithm != "HS256" {
+		return claims, ErrTokenSignature
+	}
+
+	keyID := header.KeyID
+	if keyID == "" {
+		keyID = DefaultKeyID
+	}
+	key, ok := keyring.Lookup(keyID)
+	if !ok {
+		return claims, ErrTokenSignature
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, ErrTokenMalformed
+	}
+	if !hmac.Equal(signature, mac(parts[0]+"."+parts[1], key.Secret)) {
+		return claims, ErrTokenSignature
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, ErrTokenMalformed
+	}
+	if err := json.Unmarshal(rawClaims, &claims); err != nil || claims.Subject == "" || claims.ExpiresAt == 0 {
+		return Claims{}, ErrTokenMalformed
+	}
+
+	now := time.Now().Unix()
+	if now >= claims.ExpiresAt {
+		return claims, ErrTokenExpired
+	}
+	if now < claims.NotBefore {
+		return claims, ErrTokenNotYetValid
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKeyring(t *testing.T) *Keyring {
+	t.Helper()
+
+	keyring, err := NewKeyring("current", []Key{
+		{ID: "current", Secret: []byte("current-secret")},
+		{ID: DefaultKeyID, Secret: []byte("default-secret")},
+		{ID: "retired", Secret: []byte("retired-secret"), RetireAt: time.Now().Add(-time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+// signedToken builds a JWT from raw header and claims values, signed with
+// secret, so tests can produce tokens IssueToken never would.
+func signedToken(t *testing.T, header, claims interface{}, secret string) string {
+	t.Helper()
+
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(rawHeader) + "." + base64.RawURLEncoding.EncodeToString(rawClaims)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac(signingInput, []byte(secret)))
+}
+
+func TestIssueAndParseToken(t *testing.T) {
+	keyring := testKeyring(t)
+	claims := NewClaims("alice", "admin", time.Hour)
+	claims.SessionID = "session-1"
+
+	token, err := IssueToken(claims, keyring)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+
+	parsed, err := ParseToken(token, keyring)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if parsed != claims {
+		t.Errorf("ParseToken = %+v, want %+v", parsed, claims)
+	}
+}
+
+func TestParseTokenRejects(t *testing.T) {
+	keyring := testKeyring(t)
+	now := time.Now()
+	valid := NewClaims("alice", "user", time.Hour)
+	hs256 := func(kid string) jwtHeader { return jwtHeader{Algorithm: "HS256", KeyID: kid, Type: "JWT"} }
+
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Minute).Unix()
+	early := valid
+	early.NotBefore = now.Add(time.Hour).Unix()
+	noSubject := valid
+	noSubject.Subject = ""
+
+	issued, err := IssueToken(valid, keyring)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	parts := strings.Split(issued, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"empty", "", ErrTokenMalformed},
+		{"two parts", parts[0] + "." + parts[1], ErrTokenMalformed},
+		{"header not base64", "!." + parts[1] + "." + parts[2], ErrTokenMalformed},
+		{"alg none", signedToken(t, jwtHeader{Algorithm: "none", KeyID: "current"}, valid, "current-secret"), ErrTokenSignature},
+		{"alg HS512", signedToken(t, jwtHeader{Algorithm: "HS512", KeyID: "current"}, valid, "current-secret"), ErrTokenSignature},
+		{"unknown kid", signedToken(t, hs256("other"), valid, "current-secret"), ErrTokenSignature},
+		{"retired kid", signedToken(t, hs256("retired"), valid, "retired-secret"), ErrTokenSignature},
+		{"wrong secret", signedToken(t, hs256("current"), valid, "default-secret"), ErrTokenSignature},
+		{"tampered claims", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"root","exp":9999999999}`)) + "." + parts[2], ErrTokenSignature},
+		{"no subject", signedToken(t, hs256("current"), noSubject, "current-secret"), ErrTokenMalformed},
+		{"expired", signedToken(t, hs256("current"), expired, "current-secret"), ErrTokenExpired},
+		{"not yet valid", signedToken(t, hs256("current"), early, "current-secret"), ErrTokenNotYetValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseToken(tt.token, keyring); !errors.Is(err, tt.want) {
+				t.Errorf("ParseToken error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimsIssuedBefore(t *testing.T) {
+	revokedAt := time.UnixMilli(1_700_000_000_500)
+
+	tests := []struct {
+		name   string
+		claims Claims
+		want   bool
+	}{
+		{"earlier millisecond", Claims{IssuedAt: 1_700_000_000, IssuedAtMillis: 1_700_000_000_499}, true},
+		{"same millisecond", Claims{IssuedAt: 1_700_000_000, IssuedAtMillis: 1_700_000_000_500}, true},
+		{"later in the same second", Claims{IssuedAt: 1_700_000_000, IssuedAtMillis: 1_700_000_000_501}, false},
+		{"later second", Claims{IssuedAt: 1_700_000_001, IssuedAtMillis: 1_700_000_001_000}, false},
+		{"seconds only, same second", Claims{IssuedAt: 1_700_000_000}, true},
+		{"seconds only, later second", Claims{IssuedAt: 1_700_000_001}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.IssuedBefore(revokedAt); got != tt.want {
+				t.Errorf("IssuedBefore = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultKeyID names the key built from the single auth.secret setting. It is
+// also assumed for tokens issued before tokens carried a key ID.
+const DefaultKeyID = "default"
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Key is one signing secret. A key with RetireAt set stops verifying tokens
+// at that time; until then it keeps verifying tokens it signed while active.
+type Key struct {
+	ID       string
+	Secret   []byte
+	RetireAt time.Time
+}
+
+func (k Key) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+// Keyring holds the keys that may verify tokens and names the one that signs
+// new tokens. It is safe for concurrent use and can be replaced in place, so
+// keys can be rotated without restarting the server.
+type Keyring struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string]Key
+}
+
+func NewKeyring(active string, keys []Key) (*Keyring, error) {
+	k := &Keyring{}
+	if err := k.Replace(active, keys); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Replace swaps in a new set of keys. On error the keyring is left unchanged.
+func (k *Keyring) Replace(active string, keys []Key) error {
+	byID := make(map[string]Key, len(keys))
+	for _, key := range keys {
+		if !keyIDPattern.MatchString(key.ID) {
+			return fmt.Errorf("invalid key id %q", key.ID)
+		}
+		if len(key.Secret) == 0 {
+			return fmt.Errorf("key %q has no secret", key.ID)
+		}
+		if _, exists := byID[key.ID]; exists {
+			return fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		byID[key.ID] = key
+	}
+
+	signing, exists := byID[active]
+	if !exists {
+		return fmt.Errorf("active key %q is not in the keyring", active)
+	}
+	if signing.retired(time.Now()) {
+		return fmt.Errorf("active key %q is retired", active)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.active = active
+	k.keys = byID
+	return nil
+}
+
+// Signing returns the key new tokens are signed with.
+func (k *Keyring) Signing() Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.keys[k.active]
+}
+
+// Lookup returns the key with the given ID if it may still verify tokens.
+func (k *Keyring) Lookup(id string) (Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, exists := k.keys[id]
+	if !exists || key.retired(time.Now()) {
+		return Key{}, false
+	}
+	return key, true
+}
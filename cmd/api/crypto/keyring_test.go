@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyringReplace(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		active  string
+		keys    []Key
+		wantErr bool
+	}{
+		{"valid", "a", []Key{{ID: "a", Secret: []byte("s")}, {ID: "b", Secret: []byte("t"), RetireAt: past}}, false},
+		{"invalid id", "a b", []Key{{ID: "a b", Secret: []byte("s")}}, true},
+		{"empty id", "", []Key{{ID: "", Secret: []byte("s")}}, true},
+		{"no secret", "a", []Key{{ID: "a"}}, true},
+		{"duplicate id", "a", []Key{{ID: "a", Secret: []byte("s")}, {ID: "a", Secret: []byte("t")}}, true},
+		{"active missing", "c", []Key{{ID: "a", Secret: []byte("s")}}, true},
+		{"active retired", "a", []Key{{ID: "a", Secret: []byte("s"), RetireAt: past}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring := testKeyring(t)
+
+			err := keyring.Replace(tt.active, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Replace error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := tt.active
+			if tt.wantErr {
+				want = "current"
+			}
+			if got := keyring.Signing().ID; got != want {
+				t.Errorf("Signing().ID = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestKeyringLookup(t *testing.T) {
+	keyring := testKeyring(t)
+
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"current", true},
+		{DefaultKeyID, true},
+		{"retired", false},
+		{"missing", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if _, ok := keyring.Lookup(tt.id); ok != tt.want {
+				t.Errorf("Lookup(%q) ok = %v, want %v", tt.id, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTokenWithoutKeyIDUsesDefaultKey(t *testing.T) {
+	keyring := testKeyring(t)
+	claims := NewClaims("alice", "user", time.Hour)
+
+	token := signedToken(t, jwtHeader{Algorithm: "HS256", Type: "JWT"}, claims, "default-secret")
+	if _, err := ParseToken(token, keyring); err != nil {
+		t.Errorf("ParseToken: %v", err)
+	}
+}
+
+func TestVerifyAfterRotation(t *testing.T) {
+	keyring := testKeyring(t)
+	token := keyring.Sign([]byte("payload"))
+
+	// The old signing key keeps verifying until it retires.
+	err := keyring.Replace("next", []Key{
+		{ID: "next", Secret: []byte("next-secret")},
+		{ID: "current", Secret: []byte("current-secret")},
+	})
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if _, err := keyring.Verify(token); err != nil {
+		t.Errorf("Verify with rotated key: %v", err)
+	}
+
+	err = keyring.Replace("next", []Key{
+		{ID: "next", Secret: []byte("next-secret")},
+		{ID: "current", Secret: []byte("current-secret"), RetireAt: time.Now().Add(-time.Second)},
+	})
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if _, err := keyring.Verify(token); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with retired key error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters for newly hashed passwords. Hashes record the
+// parameters they were made with, so raising these only affects new hashes;
+// NeedsRehash reports older ones so they can be upgraded at the next login.
+const (
+	argonMemory  = 64 * 1024
+	argonTime    = 3
+	argonThreads = 2
+	argonSaltLen = 16
+	argonKeyLen  = 32
+)
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// DummyHash is an argon2id hash, made with the current parameters, that no
+// account uses. Logins for unknown usernames verify against it so that they
+// take as long as a wrong password and do not reveal which usernames exist.
+const DummyHash = "$argon2id$v=19$m=65536,t=3,p=2$t3CqyJVUNqpayVGTjieC2g$gh7RkcjBcpNO1OulcJa8JhnBbAUfWM0FdPNj8ba/p0w"
+
+type argonParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// HashPassword derives an argon2id hash of password with a random salt,
+// encoded as $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword reports whether password matches hash. Besides argon2id
+// hashes it accepts the unsalted SHA-256 hex digests written by earlier
+// versions, which NeedsRehash flags for upgrading.
+func VerifyPassword(password string, hash string) bool {
+	if isLegacyHash(hash) {
+		sum := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hash)) == 1
+	}
+
+	params, salt, key, err := decodeArgonHash(hash)
+	if err != nil {
+		return false
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1
+}
+
+// NeedsRehash reports whether hash should be replaced by a fresh
+// HashPassword result: it is a legacy SHA-256 digest or was made with
+// parameters other than the current ones.
+func NeedsRehash(hash string) bool {
+	params, _, key, err := decodeArgonHash(hash)
+	if err != nil {
+		return true
+	}
+	return params != (argonParams{argonMemory, argonTime, argonThreads}) || len(key) != argonKeyLen
+}
+
+func isLegacyHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+func decodeArgonHash(hash string) (argonParams, []byte, []byte, error) {
+	var params argonParams
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if params.memory == 0 || params.time == 0 || params.threads == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	return params, salt, key, nil
+}
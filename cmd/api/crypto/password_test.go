@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// legacyPasswordHash is the unsalted SHA-256 hex digest of "password", as
+// written by versions before argon2id.
+const legacyPasswordHash = "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"
+
+// argonHash builds an argon2id hash of password with the given parameters,
+// for hashes made before the current parameters were chosen.
+func argonHash(password string, memory, time uint32, threads uint8, keyLen uint32) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Errorf("hash %q is not argon2id", hash)
+	}
+	if !VerifyPassword("correct horse", hash) {
+		t.Error("VerifyPassword rejected the hashed password")
+	}
+	if VerifyPassword("correct horsE", hash) {
+		t.Error("VerifyPassword accepted a different password")
+	}
+	if NeedsRehash(hash) {
+		t.Error("NeedsRehash reported a fresh hash")
+	}
+
+	again, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if again == hash {
+		t.Error("two hashes of the same password share a salt")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	old := argonHash("password", 16*1024, 1, 1, 32)
+	parts := strings.Split(old, "$")
+	withPart := func(i int, value string) string {
+		changed := append([]string{}, parts...)
+		changed[i] = value
+		return strings.Join(changed, "$")
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+	}{
+		{"legacy digest", "password", legacyPasswordHash, true},
+		{"legacy digest, wrong password", "Password", legacyPasswordHash, false},
+		{"legacy digest in upper case", "password", strings.ToUpper(legacyPasswordHash), false},
+		{"older parameters", "password", old, true},
+		{"older parameters, wrong password", "passwort", old, false},
+		{"tampered memory", "password", withPart(3, "m=8192,t=1,p=1"), false},
+		{"tampered time", "password", withPart(3, "m=16384,t=2,p=1"), false},
+		{"tampered salt", "password", withPart(4, base64.RawStdEncoding.EncodeToString([]byte("fedcba9876543210"))), false},
+		{"truncated key", "password", withPart(5, parts[5][:20]), false},
+		{"argon2i", "password", withPart(1, "argon2i"), false},
+		{"other version", "password", withPart(2, "v=16"), false},
+		{"zero memory", "password", withPart(3, "m=0,t=1,p=1"), false},
+		{"zero threads", "password", withPart(3, "m=16384,t=1,p=0"), false},
+		{"unparsable parameters", "password", withPart(3, "m=lots"), false},
+		{"salt not base64", "password", withPart(4, "!!!"), false},
+		{"empty key", "password", withPart(5, ""), false},
+		{"missing field", "password", strings.Join(parts[:5], "$"), false},
+		{"empty", "", "", false},
+		{"plain text", "password", "password", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPassword(tt.password, tt.hash); got != tt.want {
+				t.Errorf("VerifyPassword(%q, %q) = %v, want %v", tt.password, tt.hash, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"current parameters", argonHash("password", argonMemory, argonTime, argonThreads, argonKeyLen), false},
+		{"legacy digest", legacyPasswordHash, true},
+		{"less memory", argonHash("password", 16*1024, argonTime, argonThreads, argonKeyLen), true},
+		{"fewer passes", argonHash("password", argonMemory, 1, argonThreads, argonKeyLen), true},
+		{"fewer threads", argonHash("password", argonMemory, argonTime, 1, argonKeyLen), true},
+		{"shorter key", argonHash("password", argonMemory, argonTime, argonThreads, 16), true},
+		{"malformed", "$argon2id$v=19$m=65536", true},
+		// Unknown usernames only take as long as real ones while the dummy
+		// hash keeps up with the current parameters.
+		{"dummy hash", DummyHash, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewOpaqueToken returns a random URL-safe token carrying 256 bits of
+// entropy, for secrets that are looked up rather than verified.
+func NewOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the SHA-256 hex digest under which an opaque token is
+// stored. A fast hash is enough here because the token is already random.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix starts every API key, which tells them apart from access tokens
+// and makes leaked keys easy to search for.
+const APIKeyPrefix = "asv_"
+
+// NewAPIKey returns a new random API key.
+func NewAPIKey() (string, error) {
+	token, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + token, nil
+}
@@ -0,0 +1,46 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Sign returns payload encoded as URL-safe base64, the ID of the keyring's
+// signing key and an HMAC-SHA256 over both, joined by dots.
+func (k *Keyring) Sign(payload []byte) string {
+	key := k.Signing()
+	signingInput := base64.RawURLEncoding.EncodeToString(payload) + "." + key.ID
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac(signingInput, key.Secret))
+}
+
+// Verify checks a token produced by Sign with any key that has not retired
+// and returns its payload.
+func (k *Keyring) Verify(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidSignature
+	}
+
+	key, ok := k.Lookup(parts[1])
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+
+	sum, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sum, mac(parts[0]+"."+parts[1], key.Secret)) {
+		return nil, ErrInvalidSignature
+	}
+
+	return base64.RawURLEncoding.DecodeString(parts[0])
+}
+
+func mac(data string, secret []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
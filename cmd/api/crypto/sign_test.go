@@ -0,0 +1,52 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	keyring := testKeyring(t)
+	payload := []byte(`{"collection":"items","after":"42"}`)
+
+	token := keyring.Sign(payload)
+	got, err := keyring.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("Verify = %s, want %s", got, payload)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	keyring := testKeyring(t)
+	token := keyring.Sign([]byte("payload"))
+	parts := strings.Split(token, ".")
+	otherPayload := base64.RawURLEncoding.EncodeToString([]byte("other"))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"missing signature", parts[0] + "." + parts[1]},
+		{"extra part", token + ".x"},
+		{"changed payload", otherPayload + "." + parts[1] + "." + parts[2]},
+		{"changed key id", parts[0] + "." + DefaultKeyID + "." + parts[2]},
+		{"unknown key id", parts[0] + ".missing." + parts[2]},
+		{"signature not base64", parts[0] + "." + parts[1] + ".!!"},
+		{"truncated signature", parts[0] + "." + parts[1] + "." + parts[2][:10]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keyring.Verify(tt.token); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
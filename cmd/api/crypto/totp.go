@@ -0,0 +1,127 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters, per RFC 6238 with the defaults every authenticator app
+// supports: HMAC-SHA1, six digits and a 30 second step.
+const (
+	totpDigits = 6
+	totpModulo = 1000000
+	totpPeriod = 30
+	// totpSkew is how many steps before and after the current one are
+	// accepted, to allow for clock drift and slow typing.
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random 160-bit TOTP secret, base32 encoded as
+// authenticator apps expect it.
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI that authenticator apps
+// import, usually from a QR code.
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(totpDigits))
+	values.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// TOTPCode returns the code for the time step containing t.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return totpCode(key, totpStep(t)), nil
+}
+
+// VerifyTOTP checks code against the steps around now and returns the step it
+// matched, which callers record so the same code cannot be used again.
+func VerifyTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	h := hmac.New(sha1.New, key)
+	h.Write(counter[:])
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulo)
+}
+
+// recoveryCodeAlphabet leaves out characters that are easily confused.
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// NewRecoveryCodes returns n random single-use recovery codes of the form
+// xxxxx-xxxxx. They are stored with HashToken like other opaque tokens.
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	alphabetSize := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	b := make([]byte, 10)
+	for i := range codes {
+		for j := range b {
+			k, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return nil, err
+			}
+			b[j] = recoveryCodeAlphabet[k.Int64()]
+		}
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode undoes the formatting users tend to add when typing a
+// recovery code back in.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	if len(code) == 10 && !strings.Contains(code, "-") {
+		code = code[:5] + "-" + code[5:]
+	}
+	return code
+}
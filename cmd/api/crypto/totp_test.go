@@ -0,0 +1,138 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed from RFC 6238 appendix B, "12345678901234567890",
+// base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC lists eight-digit codes; six-digit codes are their last six
+	// digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatalf("TOTPCode: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("TOTPCode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := totpStep(now)
+	code := func(t time.Time) string {
+		c, err := TOTPCode(rfc6238Secret, t)
+		if err != nil {
+			panic(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfc6238Secret, code(now), step, true},
+		{"lower case secret", strings.ToLower(rfc6238Secret), code(now), step, true},
+		{"previous step", rfc6238Secret, code(now.Add(-totpPeriod * time.Second)), step - 1, true},
+		{"next step", rfc6238Secret, code(now.Add(totpPeriod * time.Second)), step + 1, true},
+		{"two steps ago", rfc6238Secret, code(now.Add(-2 * totpPeriod * time.Second)), 0, false},
+		{"two steps ahead", rfc6238Secret, code(now.Add(2 * totpPeriod * time.Second)), 0, false},
+		{"wrong code", rfc6238Secret, "000000", 0, false},
+		{"too short", rfc6238Secret, code(now)[:5], 0, false},
+		{"too long", rfc6238Secret, code(now) + "0", 0, false},
+		{"invalid secret", "not base32!", code(now), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := VerifyTOTP(tt.secret, tt.code, now)
+			if gotOK != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("VerifyTOTP = (%d, %v), want (%d, %v)", gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecretRoundTrips(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret: %v", err)
+	}
+
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	if _, ok := VerifyTOTP(secret, code, now); !ok {
+		t.Errorf("VerifyTOTP rejected the current code for a new secret")
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"abcde-fghjk", "abcde-fghjk"},
+		{"ABCDE-FGHJK", "abcde-fghjk"},
+		{"  abcde-fghjk\n", "abcde-fghjk"},
+		{"abcdefghjk", "abcde-fghjk"},
+		{"abcde fghjk", "abcde-fghjk"},
+		{"abcde", "abcde"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := NormalizeRecoveryCode(tt.in); got != tt.want {
+				t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if NormalizeRecoveryCode(code) != code {
+			t.Errorf("code %q is not in normal form", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+const (
+	cursorAfter  = "a"
+	cursorBefore = "b"
+)
+
+var auditLogFilters = []string{"user_id", "entity", "action"}
+
+// CursorResult is the response body of a list endpoint in cursor mode. The
+// cursors are omitted when there is nothing further in that direction.
+type CursorResult struct {
+	Data       interface{} `json:"data"`
+	Limit      int         `json:"limit"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+}
+
+// cursorPayload is the signed content of a cursor token. Resource ties the
+// cursor to one endpoint so it cannot be replayed against another.
+type cursorPayload struct {
+	Resource  string `json:"r"`
+	Direction string `json:"d"`
+	Time      string `json:"t"`
+	ID        string `json:"id"`
+}
+
+// isCursorRequest reports whether a list request asked for cursor pagination.
+// An empty cursor parameter requests the first page.
+func isCursorRequest(r *http.Request) bool {
+	return r.URL.Query().Has("cursor")
+}
+
+// cursorFromQuery reads cursor, limit and the given filter keys from the
+// request's query string.
+func cursorFromQuery(r *http.Request, resource string, filters []string) (storage.CursorParams, error) {
+	query := r.URL.Query()
+	params := storage.CursorParams{
+		Limit:  storage.DefaultPerPage,
+		Filter: map[string]string{},
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > storage.MaxPerPage {
+			return params, errors.New("The limit parameter must be between 1 and " + strconv.Itoa(storage.MaxPerPage))
+		}
+		params.Limit = limit
+	}
+
+	if token := query.Get("cursor"); token != "" {
+		payload, key, err := decodeCursor(token)
+		if err != nil || payload.Resource != resource {
+			return params, errors.New("Invalid cursor")
+		}
+		if payload.Direction == cursorBefore {
+			params.Before = &key
+		} else {
+			params.After = &key
+		}
+	}
+
+	for _, key := range filters {
+		if value := query.Get(key); value != "" {
+			params.Filter[key] = value
+		}
+	}
+
+	return params, nil
+}
+
+// cursorResult turns a storage page into the response body, signing a
+// next_cursor after its last row and a prev_cursor before its first.
+func cursorResult(resource string, params storage.CursorParams, page storage.CursorPage) CursorResult {
+	result := CursorResult{Data: page.Data, Limit: params.Limit}
+
+	if page.HasNext && page.Last != nil {
+		result.NextCursor = encodeCursor(resource, cursorAfter, *page.Last)
+	}
+	if page.HasPrev && page.First != nil {
+		result.PrevCursor = encodeCursor(resource, cursorBefore, *page.First)
+	}
+	return result
+}
+
+func encodeCursor(resource, direction string, key storage.CursorKey) string {
+	payload, _ := json.Marshal(cursorPayload{
+		Resource:  resource,
+		Direction: direction,
+		Time:      key.Time.UTC().Format(time.RFC3339Nano),
+		ID:        key.ID,
+	})
+	return keyring.Sign(payload)
+}
+
+func decodeCursor(token string) (cursorPayload, storage.CursorKey, error) {
+	var payload cursorPayload
+
+	raw, err := keyring.Verify(token)
+	if err != nil {
+		return payload, storage.CursorKey{}, err
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return payload, storage.CursorKey{}, err
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, payload.Time)
+	if err != nil {
+		return payload, storage.CursorKey{}, err
+	}
+	return payload, storage.CursorKey{Time: t, ID: payload.ID}, nil
+}
@@ -1,11 +1,13 @@
 let token = localStorage.getItem('token');
+let refreshToken = localStorage.getItem('refreshToken');
+let refreshing = null;
 let currentUser = JSON.parse(localStorage.getItem('currentUser'));
 
 
 const api = {
     baseUrl: '/api',
     
-    async request(endpoint, options = {}) {
+    async request(endpoint, options = {}, retried = false) {
         const headers = {
             'Content-Type': 'application/json',
             ...options.headers
@@ -22,31 +24,112 @@ const api = {
         
         const data = await response.json();
         
+        if (response.status === 401 && token && data.code === 'token_expired') {
+            if (!retried && refreshToken && await this.refresh()) {
+                return this.request(endpoint, options, true);
+            }
+            this.logout();
+        } else if (response.status === 401 && token && data.code === 'token_revoked') {
+            this.logout();
+        } else if (response.status === 403 && data.code === 'password_change_required') {
+            this.setMustChangePassword(true);
+            navigateTo('change-password');
+        }
+        
         if (!response.ok) {
-            throw new Error(data.error || 'Something went wrong');
+            const details = Array.isArray(data.details)
+                ? ': ' + data.details.map(d => d.message).join('; ')
+                : '';
+            throw new Error((data.error || 'Something went wrong') + details);
         }
         
         return data;
     },
     
     async login(username, password) {
-        const result = await this.request('/auth/login', {
+        let result = await this.request('/auth/login', {
             method: 'POST',
             body: JSON.stringify({ username, password })
         });
         
-        token = result.data.token;
+        if (result.data.two_factor_required) {
+            const code = prompt('Enter the code from your authenticator app or a recovery code');
+            if (!code) {
+                throw new Error('Two-factor authentication required');
+            }
+            const isRecoveryCode = code.includes('-');
+            result = await this.request('/auth/2fa/verify', {
+                method: 'POST',
+                body: JSON.stringify({
+                    challenge_token: result.data.challenge_token,
+                    code: isRecoveryCode ? '' : code.trim(),
+                    recovery_code: isRecoveryCode ? code.trim() : ''
+                })
+            });
+        }
+        
+        this.storeTokens(result.data);
         currentUser = {
             id: result.data.user_id,
-            username: result.data.username
+            username: result.data.username,
+            mustChangePassword: result.data.must_change_password
         };
         
-        localStorage.setItem('token', token);
         localStorage.setItem('currentUser', JSON.stringify(currentUser));
         
         return result;
     },
     
+    async changePassword(currentPassword, newPassword) {
+        const result = await this.request('/me/password', {
+            method: 'POST',
+            body: JSON.stringify({ current_password: currentPassword, new_password: newPassword })
+        });
+        this.setMustChangePassword(false);
+        return result;
+    },
+    
+    // setMustChangePassword records whether the server only lets the user
+    // change their password, as it does for accounts set up by an admin.
+    setMustChangePassword(required) {
+        if (currentUser) {
+            currentUser.mustChangePassword = required;
+            localStorage.setItem('currentUser', JSON.stringify(currentUser));
+        }
+    },
+    
+    // Refresh tokens are single-use, so concurrent requests that all find
+    // their access token expired must share one refresh call.
+    refresh() {
+        if (!refreshing) {
+            refreshing = fetch(`${this.baseUrl}/auth/refresh`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ refresh_token: refreshToken })
+            })
+                .then(async response => {
+                    if (!response.ok) {
+                        return false;
+                    }
+                    const result = await response.json();
+                    this.storeTokens(result.data);
+                    return true;
+                })
+                .catch(() => false)
+                .finally(() => {
+                    refreshing = null;
+                });
+        }
+        return refreshing;
+    },
+    
+    storeTokens(data) {
+        token = data.token;
+        refreshToken = data.refresh_token;
+        localStorage.setItem('token', token);
+        localStorage.setItem('refreshToken', refreshToken);
+    },
+    
     async register(username, password, email) {
         return await this.request('/auth/register', {
             method: 'POST',
@@ -54,16 +137,30 @@ const api = {
         });
     },
     
-    async getUsers() {
-        return await this.request('/users');
+    async verifyEmail(token) {
+        return await this.request('/auth/verify-email', {
+            method: 'POST',
+            body: JSON.stringify({ token })
+        });
+    },
+    
+    async resetPassword(token, newPassword) {
+        return await this.request('/auth/password-reset/confirm', {
+            method: 'POST',
+            body: JSON.stringify({ token, new_password: newPassword })
+        });
+    },
+    
+    async getUsers(query = '') {
+        return await this.request(`/users${query}`);
     },
     
     async getUser(id) {
         return await this.request(`/users/${id}`);
     },
     
-    async getItems() {
-        return await this.request('/items');
+    async getItems(query = '') {
+        return await this.request(`/items${query}`);
     },
     
     async getItem(id) {
@@ -91,9 +188,22 @@ const api = {
     },
     
     logout() {
+        if (token) {
+            fetch(`${this.baseUrl}/auth/logout`, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'Authorization': `Bearer ${token}`
+                },
+                body: JSON.stringify({ refresh_token: refreshToken })
+            }).catch(() => {});
+        }
+        
         localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
         localStorage.removeItem('currentUser');
         token = null;
+        refreshToken = null;
         currentUser = null;
         navigateTo('login');
     }
@@ -112,6 +222,8 @@ function showPage(pageId) {
 function navigateTo(pageId) {
     if (!token && !['login', 'register'].includes(pageId)) {
         pageId = 'login';
+    } else if (token && currentUser && currentUser.mustChangePassword) {
+        pageId = 'change-password';
     }
     
     showPage(pageId);
@@ -165,6 +277,33 @@ document.addEventListener('DOMContentLoaded', () => {
     });
     
     
+    document.getElementById('change-password-form').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const currentPassword = document.getElementById('current-password').value;
+        const newPassword = document.getElementById('new-password').value;
+        
+        if (newPassword !== document.getElementById('confirm-password').value) {
+            showMessage('change-password-message', 'The new passwords do not match', 'error');
+            return;
+        }
+        
+        try {
+            await api.changePassword(currentPassword, newPassword);
+            document.getElementById('change-password-form').reset();
+            navigateTo('dashboard');
+            showMessage('dashboard-message', 'Password changed successfully!', 'success');
+        } catch (error) {
+            showMessage('change-password-message', error.message, 'error');
+        }
+    });
+    
+    
+    document.getElementById('change-password-logout-btn').addEventListener('click', (e) => {
+        e.preventDefault();
+        api.logout();
+    });
+    
+    
     document.getElementById('register-form').addEventListener('submit', async (e) => {
         e.preventDefault();
         const username = document.getElementById('register-username').value;
@@ -172,8 +311,11 @@ document.addEventListener('DOMContentLoaded', () => {
         const email = document.getElementById('register-email').value;
         
         try {
-            await api.register(username, password, email);
-            showMessage('register-message', 'Registration successful! Please login.', 'success');
+            const result = await api.register(username, password, email);
+            const message = result.data.email_verification_required
+                ? 'Registration successful! Check your email to verify your address, then login.'
+                : 'Registration successful! Please login.';
+            showMessage('register-message', message, 'success');
             document.getElementById('register-form').reset();
             setTimeout(() => navigateTo('login'), 2000);
         } catch (error) {
@@ -272,9 +414,39 @@ document.addEventListener('DOMContentLoaded', () => {
     } else {
         navigateTo('login');
     }
+    
+    handleEmailLink();
 });
 
 
+// handleEmailLink completes the action of a verification or password reset
+// link, which carries its token in the URL fragment.
+async function handleEmailLink() {
+    const [action, linkToken] = window.location.hash.slice(1).split('=');
+    if (!linkToken) {
+        return;
+    }
+    history.replaceState(null, '', window.location.pathname);
+    
+    try {
+        if (action === 'verify-email') {
+            await api.verifyEmail(linkToken);
+            alert('Your email address has been verified.');
+        } else if (action === 'reset-password') {
+            const newPassword = prompt('Choose a new password');
+            if (!newPassword) {
+                return;
+            }
+            await api.resetPassword(linkToken, newPassword);
+            api.logout();
+            showMessage('login-message', 'Password reset. Please login with your new password.', 'success');
+        }
+    } catch (error) {
+        alert(error.message);
+    }
+}
+
+
 function showMessage(elementId, message, type) {
     const element = document.getElementById(elementId);
     element.textContent = message;
@@ -296,8 +468,8 @@ function updateUserInfo() {
 async function loadDashboard() {
     try {
         const stats = {
-            users: (await api.getUsers()).data.length,
-            items: (await api.getItems()).data.length
+            users: (await api.getUsers('?per_page=1')).data.total_items,
+            items: (await api.getItems('?per_page=1')).data.total_items
         };
         
         document.getElementById('stats-users').textContent = stats.users;
@@ -309,8 +481,8 @@ async function loadDashboard() {
 
 async function loadUsers() {
     try {
-        const result = await api.getUsers();
-        const users = result.data;
+        const result = await api.getUsers('?sort=name');
+        const users = result.data.data;
         const tbody = document.getElementById('users-table-body');
         tbody.innerHTML = '';
         
@@ -330,8 +502,8 @@ async function loadUsers() {
 
 async function loadItems() {
     try {
-        const result = await api.getItems();
-        const items = result.data;
+        const result = await api.getItems('?sort=created_at&order=desc');
+        const items = result.data.data;
         const tbody = document.getElementById('items-table-body');
         tbody.innerHTML = '';
         
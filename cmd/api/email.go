@@ -0,0 +1,294 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/mailer"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+type EmailTokenRequest struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// verifyEmailHandler confirms the email address of the user a verification
+// link was sent to. The link only counts while the address it was sent to is
+// still the user's.
+func verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req EmailTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	token, user, ok := emailTokenUser(w, req.Token, storage.EmailTokenVerify)
+	if !ok {
+		return
+	}
+	if !strings.EqualFold(token.Email, user.Email) {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	if err := st.UseEmailToken(token.ID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	if user.MustVerifyEmail {
+		user.MustVerifyEmail = false
+		if err := st.UpdateUser(user); err != nil {
+			helper.RespondWithError(w, http.StatusInternalServerError, "Could not verify email")
+			return
+		}
+		recordAudit("verify_email", "user", user.ID, user.ID, "verified "+user.Email)
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Email verified", nil)
+}
+
+// resendVerificationHandler sends a new verification link to the
+// authenticated user, replacing any earlier one.
+func resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	user, err := st.GetUser(userID)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if !user.MustVerifyEmail {
+		helper.RespondWithError(w, http.StatusConflict, "Email is already verified")
+		return
+	}
+	if mail == nil {
+		helper.RespondWithError(w, http.StatusServiceUnavailable, "Email is not configured")
+		return
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not send verification email")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Verification email sent", nil)
+}
+
+// requestPasswordResetHandler emails a password reset link to the account
+// with the given address, unless the address is still unverified. The
+// response is the same either way, so that it cannot be used to find out
+// whether such an account exists.
+func requestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	if mail == nil {
+		helper.RespondWithError(w, http.StatusServiceUnavailable, "Password reset is not available")
+		return
+	}
+
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !helper.ValidateEmail(req.Email) {
+		helper.RespondWithError(w, http.StatusBadRequest, "A valid email is required")
+		return
+	}
+
+	if user, err := st.GetUserByEmail(req.Email); err == nil && !user.Disabled() && !user.MustVerifyEmail {
+		if err := sendPasswordResetEmail(user); err != nil {
+			log.Printf("Could not send password reset email to user %s: %v", user.ID, err)
+		}
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "If an account with that email exists, a password reset link has been sent", nil)
+}
+
+// confirmPasswordResetHandler sets a new password with the token from a
+// reset link, as long as the link went to the user's current address. Every
+// session of the user is ended.
+func confirmPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	token, user, ok := emailTokenUser(w, req.Token, storage.EmailTokenPasswordReset)
+	if !ok {
+		return
+	}
+	if user.Disabled() || !strings.EqualFold(token.Email, user.Email) {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	if !checkPassword(w, req.NewPassword, user.Username, user.Email) {
+		return
+	}
+
+	passwordHash, err := crypto.HashPassword(req.NewPassword)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not reset password")
+		return
+	}
+
+	if err := st.UseEmailToken(token.ID); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	user.Password = passwordHash
+	user.MustChangePassword = false
+	if err := st.UpdateUser(user); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not reset password")
+		return
+	}
+
+	if err := st.DeleteUserEmailTokens(user.ID, storage.EmailTokenPasswordReset); err != nil {
+		log.Printf("Could not delete password reset tokens of user %s: %v", user.ID, err)
+	}
+	if err := revokeUserSessions(user.ID); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions")
+		return
+	}
+	clearLoginFailures(user.Username)
+
+	recordAudit("reset_password", "user", user.ID, user.ID, "reset password by email")
+	helper.RespondWithSuccess(w, http.StatusOK, "Password reset; log in with the new password", nil)
+}
+
+// emailTokenUser looks up the stored token for the token in a link and its
+// user, writing the error response and returning false unless the token is
+// valid for purpose.
+func emailTokenUser(w http.ResponseWriter, raw string, purpose string) (storage.EmailToken, storage.User, bool) {
+	token, err := st.GetEmailToken(crypto.HashToken(raw))
+	if err != nil || !token.Valid(purpose, time.Now()) {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return storage.EmailToken{}, storage.User{}, false
+	}
+
+	user, err := st.GetUser(token.UserID)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return storage.EmailToken{}, storage.User{}, false
+	}
+	return token, user, true
+}
+
+// sendVerificationEmail mails user a link to verify their current address,
+// replacing any earlier verification link.
+func sendVerificationEmail(user storage.User) error {
+	if err := st.DeleteUserEmailTokens(user.ID, storage.EmailTokenVerify); err != nil {
+		return err
+	}
+
+	token, err := createEmailToken(user, storage.EmailTokenVerify, verifyTokenTTL())
+	if err != nil {
+		return err
+	}
+
+	sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address for " + cfg.AppName,
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nplease confirm your email address by opening this link:\n\n%s\n\n"+
+				"The link expires in %s. If you did not sign up for %s, you can ignore this email.\n",
+			user.Username, emailLink("verify-email", token), describeDuration(verifyTokenTTL()), cfg.AppName,
+		),
+	})
+	return nil
+}
+
+func sendPasswordResetEmail(user storage.User) error {
+	token, err := createEmailToken(user, storage.EmailTokenPasswordReset, resetTokenTTL())
+	if err != nil {
+		return err
+	}
+
+	sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your " + cfg.AppName + " password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nsomeone asked to reset the password of your account. To choose a new password, open this link:\n\n%s\n\n"+
+				"The link expires in %s and works once. If you did not ask for a reset, you can ignore this email.\n",
+			user.Username, emailLink("reset-password", token), describeDuration(resetTokenTTL()),
+		),
+	})
+	return nil
+}
+
+// createEmailToken stores a new token for purpose sent to user's current
+// address and returns the token for the link.
+func createEmailToken(user storage.User, purpose string, ttl time.Duration) (string, error) {
+	token, err := crypto.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = st.CreateEmailToken(storage.EmailToken{
+		ID:        crypto.HashToken(token),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	return token, err
+}
+
+// sendMail delivers msg in the background, so that a slow mail server
+// neither holds up the request nor reveals through timing whether a message
+// was sent.
+func sendMail(msg mailer.Message) {
+	go func() {
+		if err := mail.Send(msg); err != nil {
+			log.Printf("Could not send email %q: %v", msg.Subject, err)
+		}
+	}()
+}
+
+// emailLink returns the dashboard link that hands token to the page handling
+// action.
+func emailLink(action string, token string) string {
+	return strings.TrimRight(cfg.Mail.BaseURL, "/") + "/dashboard/#" + action + "=" + token
+}
+
+// describeDuration writes d in whole hours, or in minutes when shorter than
+// two hours, for the expiry note in emails.
+func describeDuration(d time.Duration) string {
+	if d < 2*time.Hour {
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%d hours", int(d.Hours()))
+}
+
+// verifyTokenTTL is how long an email verification link works, from
+// auth.verify_expire_hrs.
+func verifyTokenTTL() time.Duration {
+	if cfg.Auth.VerifyExpireHrs <= 0 {
+		return 48 * time.Hour
+	}
+	return time.Duration(cfg.Auth.VerifyExpireHrs) * time.Hour
+}
+
+// resetTokenTTL is how long a password reset link works, from
+// auth.reset_expire_mins.
+func resetTokenTTL() time.Duration {
+	if cfg.Auth.ResetExpireMins <= 0 {
+		return time.Hour
+	}
+	return time.Duration(cfg.Auth.ResetExpireMins) * time.Minute
+}
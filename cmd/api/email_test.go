@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+// linkToken finds the token of an emailed link for action.
+func linkToken(t *testing.T, srv *testServer, action string) string {
+	t.Helper()
+
+	select {
+	case msg := <-srv.sent:
+		match := regexp.MustCompile(`#` + action + `=(\S+)`).FindStringSubmatch(msg.Body)
+		if match == nil {
+			t.Fatalf("no %s link in %q", action, msg.Body)
+		}
+		return match[1]
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no email sent")
+		return ""
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	srv := newTestServer(t, nil)
+	alice := srv.createUser("alice", storage.RoleUser)
+	session := srv.login("alice")
+
+	expect(t, "request reset", srv.call("POST", "/api/auth/password-reset", "", ForgotPasswordRequest{Email: alice.Email}), http.StatusOK)
+	token := linkToken(t, srv, "reset-password")
+
+	confirm := func(password string) testResponse {
+		return srv.call("POST", "/api/auth/password-reset/confirm", "", PasswordResetConfirmRequest{Token: token, NewPassword: password})
+	}
+	expect(t, "reset", confirm("quiet-meadow-77"), http.StatusOK)
+	expect(t, "reusing the token", confirm("amber-lantern-31"), http.StatusBadRequest)
+
+	// Sessions from before the reset end, refresh tokens included.
+	expect(t, "access token from before the reset", srv.call("GET", "/api/me", session.field("token"), nil), http.StatusUnauthorized)
+	refresh := srv.call("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: session.field("refresh_token")})
+	expect(t, "refresh token from before the reset", refresh, http.StatusUnauthorized)
+
+	expect(t, "login with the old password", srv.call("POST", "/api/auth/login", "", UserLoginRequest{Username: "alice", Password: testPassword}), http.StatusUnauthorized)
+	expect(t, "login with the new password", srv.call("POST", "/api/auth/login", "", UserLoginRequest{Username: "alice", Password: "quiet-meadow-77"}), http.StatusOK)
+}
@@ -1,22 +1,19 @@
 package helper
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"regexp"
+	"strings"
 )
 
-type contextKey string
-
-const UserIDKey contextKey = "userID"
-const UserRoleKey contextKey = "userRole"
-
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
@@ -33,30 +30,33 @@ func RespondWithError(w http.ResponseWriter, code int, message string) {
 	})
 }
 
-func RespondWithSuccess(w http.ResponseWriter, code int, message string, data interface{}) {
+// RespondWithErrorCode is RespondWithError with a machine-readable error code
+// for failures that clients are expected to handle differently.
+func RespondWithErrorCode(w http.ResponseWriter, code int, errorCode string, message string) {
 	RespondWithJSON(w, code, APIResponse{
-		Success: true,
-		Message: message,
-		Data:    data,
+		Success: false,
+		Error:   message,
+		Code:    errorCode,
 	})
 }
 
-func SetUserContext(ctx context.Context, userID string) context.Context {
-	return context.WithValue(ctx, UserIDKey, userID)
-}
-
-func GetUserFromContext(ctx context.Context) (string, bool) {
-	userID, ok := ctx.Value(UserIDKey).(string)
-	return userID, ok
-}
-
-func SetUserRoleContext(ctx context.Context, role string) context.Context {
-	return context.WithValue(ctx, UserRoleKey, role)
+// RespondWithErrorDetails is RespondWithErrorCode with details listing what
+// exactly was wrong, such as every rule a password breaks.
+func RespondWithErrorDetails(w http.ResponseWriter, code int, errorCode string, message string, details interface{}) {
+	RespondWithJSON(w, code, APIResponse{
+		Success: false,
+		Error:   message,
+		Code:    errorCode,
+		Details: details,
+	})
 }
 
-func GetUserRoleFromContext(ctx context.Context) (string, bool) {
-	role, ok := ctx.Value(UserRoleKey).(string)
-	return role, ok
+func RespondWithSuccess(w http.ResponseWriter, code int, message string, data interface{}) {
+	RespondWithJSON(w, code, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    data,
+	})
 }
 
 func ValidateEmail(email string) bool {
@@ -64,15 +64,11 @@ func ValidateEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
 
-func ValidateUsername(username string) bool {
-
-	usernameRegex := regexp.MustCompile(`^[a-zA-Z0-9_]{3,20}$`)
-	return usernameRegex.MatchString(username)
-}
-
-func ValidatePassword(password string) bool {
-
-	return len(password) >= 8
+// NormalizeTagName lowercases a tag name, trims it and collapses runs of
+// whitespace so that "Sale", " sale " and "SALE" all name the same tag.
+func NormalizeTagName(name string) string {
+	whitespaceRegex := regexp.MustCompile(`\s+`)
+	return whitespaceRegex.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), " ")
 }
 
 func ExampleHelper() string {
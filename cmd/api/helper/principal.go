@@ -0,0 +1,81 @@
+package helper
+
+import (
+	"context"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+)
+
+// Principal is the authenticated caller of a request, as established by
+// middleware.AuthMiddleware. It is the only identity stored in a request
+// context and is read back through the accessors below.
+type Principal struct {
+	UserID string
+	Role   string
+	// Permissions are the permissions granted by Role.
+	Permissions []string
+	// Scopes limits what the credential may do within the role. Empty means
+	// everything the role allows.
+	Scopes    []string
+	TokenID   string
+	SessionID string
+	// APIKeyID is set instead of TokenID and SessionID for requests made
+	// with an API key.
+	APIKeyID  string
+	ExpiresAt time.Time
+	// MustChangePassword restricts the principal to the routes that need no
+	// permission until the user has changed their password.
+	MustChangePassword bool
+	// MustEnrollTwoFactor does the same until the user has enabled
+	// two-factor authentication, which their role requires.
+	MustEnrollTwoFactor bool
+	// UnverifiedAccess is set while the user has not verified their email
+	// address, to the config.Unverified* level of access they have until then.
+	UnverifiedAccess string
+}
+
+// HasScope reports whether the principal's credential covers scope.
+func (p Principal) HasScope(scope string) bool {
+	if len(p.Scopes) == 0 {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the principal may use permission: its role must grant
+// the permission and its credential's scopes, if any, must cover it.
+func (p Principal) Can(permission string) bool {
+	if !rbac.Allows(p.Permissions, permission) {
+		return false
+	}
+	return len(p.Scopes) == 0 || rbac.Allows(p.Scopes, permission)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the request's principal, or false for
+// requests that did not pass through the auth middleware.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+func GetUserFromContext(ctx context.Context) (string, bool) {
+	principal, ok := PrincipalFromContext(ctx)
+	return principal.UserID, ok
+}
+
+func GetUserRoleFromContext(ctx context.Context) (string, bool) {
+	principal, ok := PrincipalFromContext(ctx)
+	return principal.Role, ok
+}
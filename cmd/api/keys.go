@@ -0,0 +1,39 @@
+package api
+
+import (
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/internal/config"
+)
+
+// NewKeyring builds the token signing keyring from the auth section of cfg.
+func NewKeyring(cfg *config.Config) (*crypto.Keyring, error) {
+	active, keys := keysFromConfig(cfg)
+	return crypto.NewKeyring(active, keys)
+}
+
+// ReloadKeyring replaces the keys in keyring with those in cfg. Tokens signed
+// with keys that are still listed stay valid.
+func ReloadKeyring(keyring *crypto.Keyring, cfg *config.Config) error {
+	active, keys := keysFromConfig(cfg)
+	return keyring.Replace(active, keys)
+}
+
+// keysFromConfig reads auth.keys and auth.active_key. Without any keys the
+// single auth.secret becomes the key crypto.DefaultKeyID; a lone key is active
+// even when active_key is not set.
+func keysFromConfig(cfg *config.Config) (string, []crypto.Key) {
+	if len(cfg.Auth.Keys) == 0 {
+		return crypto.DefaultKeyID, []crypto.Key{{ID: crypto.DefaultKeyID, Secret: []byte(cfg.Auth.Secret)}}
+	}
+
+	keys := make([]crypto.Key, 0, len(cfg.Auth.Keys))
+	for _, key := range cfg.Auth.Keys {
+		keys = append(keys, crypto.Key{ID: key.ID, Secret: []byte(key.Secret), RetireAt: key.RetireAt})
+	}
+
+	active := cfg.Auth.ActiveKey
+	if active == "" && len(keys) == 1 {
+		active = keys[0].ID
+	}
+	return active, keys
+}
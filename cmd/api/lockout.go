@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+const errCodeLoginLocked = "login_locked"
+
+// LockoutResponse is a login attempt as shown to admins, split into what it
+// counts failures for and whether logins are currently refused.
+type LockoutResponse struct {
+	storage.LoginAttempt
+	Kind        string     `json:"kind"`
+	Value       string     `json:"value"`
+	Locked      bool       `json:"locked"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+func listLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	onlyLocked := r.URL.Query().Get("locked") == "true"
+
+	now := time.Now()
+	lockouts := []LockoutResponse{}
+	for _, attempt := range st.ListLoginAttempts() {
+		kind, value, _ := strings.Cut(attempt.ID, ":")
+		lockout := LockoutResponse{LoginAttempt: attempt, Kind: kind, Value: value}
+
+		if until := lockedUntil(attempt); until.After(now) {
+			lockout.Locked = true
+			lockout.LockedUntil = &until
+		}
+		if onlyLocked && !lockout.Locked {
+			continue
+		}
+		lockouts = append(lockouts, lockout)
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Lockouts retrieved", lockouts)
+}
+
+// clearLockoutHandler forgets the failed logins of one username or client
+// IP, such as "username:bob" or "ip:192.0.2.1", lifting any lockout.
+func clearLockoutHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := st.DeleteLoginAttempt(id); err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Lockout not found")
+		return
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("clear_lockout", "lockout", id, adminID, "cleared failed logins")
+	helper.RespondWithSuccess(w, http.StatusOK, "Lockout cleared", nil)
+}
+
+// checkLoginLockout responds with 429 and returns false while logins for
+// username, or any login from the client making r, are refused.
+func checkLoginLockout(w http.ResponseWriter, r *http.Request, username string) bool {
+	if !cfg.Lockout.Enabled {
+		return true
+	}
+
+	now := time.Now()
+	until := now
+	for _, id := range []string{storage.UsernameAttemptID(username), storage.IPAttemptID(clientIP(r))} {
+		if attempt, err := st.GetLoginAttempt(id); err == nil {
+			until = latest(until, lockedUntil(attempt))
+		}
+	}
+
+	if !until.After(now) {
+		return true
+	}
+
+	seconds := int(math.Ceil(until.Sub(now).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	helper.RespondWithErrorCode(w, http.StatusTooManyRequests, errCodeLoginLocked,
+		fmt.Sprintf("Too many failed logins; try again in %d seconds", seconds))
+	return false
+}
+
+// recordLoginFailure audits a failed login for username, whose account is
+// userID when it exists, and counts it towards a lockout of both the username
+// and the client IP. Failed logins are audited even with features.audit off.
+func recordLoginFailure(r *http.Request, username, userID, reason string) {
+	ip := clientIP(r)
+	writeAudit("login_failed", "user", userID, userID, fmt.Sprintf("%s for %q from %s", reason, username, ip))
+
+	if !cfg.Lockout.Enabled {
+		return
+	}
+	countLoginFailure(storage.UsernameAttemptID(username))
+	countLoginFailure(storage.IPAttemptID(ip))
+}
+
+// countLoginFailure adds a failure to the attempt id. The count starts over
+// once the window has passed since the last failure, or since the end of the
+// lockout that failure caused, so that lockouts keep growing for as long as
+// guessing goes on.
+func countLoginFailure(id string) {
+	now := time.Now()
+	resetBefore := now.Add(-cfg.Lockout.Window())
+	if attempt, err := st.GetLoginAttempt(id); err == nil {
+		resetBefore = resetBefore.Add(-lockedUntil(attempt).Sub(attempt.LastFailedAt))
+	}
+
+	if _, err := st.RecordLoginFailure(id, now, resetBefore); err != nil {
+		log.Printf("Could not record failed login for %s: %v", id, err)
+	}
+}
+
+// clearLoginFailures forgets the failed logins for username after it logged
+// in. Failures from the client IP keep counting, as they may be guesses at
+// other accounts.
+func clearLoginFailures(username string) {
+	if _, err := st.GetLoginAttempt(storage.UsernameAttemptID(username)); err != nil {
+		return
+	}
+	if err := st.DeleteLoginAttempt(storage.UsernameAttemptID(username)); err != nil {
+		log.Printf("Could not clear failed logins for %s: %v", username, err)
+	}
+}
+
+// lockedUntil is when the lockout caused by attempt ends, which is its last
+// failure if it caused none.
+func lockedUntil(attempt storage.LoginAttempt) time.Time {
+	threshold := cfg.Lockout.MaxAttempts
+	if strings.HasPrefix(attempt.ID, storage.IPAttemptID("")) {
+		threshold = cfg.Lockout.MaxIPAttempts
+	}
+	return attempt.LastFailedAt.Add(cfg.Lockout.Delay(attempt.Failures, threshold))
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
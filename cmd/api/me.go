@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+type MeUpdateRequest struct {
+	Email           string `json:"email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func getMeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+	respondWithUser(w, "User retrieved", userID)
+}
+
+// updateMeHandler changes the authenticated user's email after checking their
+// current password. Password reset links sent to the old address stop
+// working.
+func updateMeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	user, err := st.GetUser(userID)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var req MeUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if !crypto.VerifyPassword(req.CurrentPassword, user.Password) {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+	if !helper.ValidateEmail(req.Email) {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid email format")
+		return
+	}
+	if emailTaken(req.Email, user.ID) {
+		helper.RespondWithError(w, http.StatusConflict, "Email already in use")
+		return
+	}
+
+	changed := !strings.EqualFold(user.Email, req.Email)
+	user.Email = req.Email
+	if changed && mail != nil {
+		user.MustVerifyEmail = true
+	}
+	if err := st.UpdateUser(user); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not update user")
+		return
+	}
+
+	if changed {
+		if err := st.DeleteUserEmailTokens(user.ID, storage.EmailTokenPasswordReset); err != nil {
+			log.Printf("Could not delete password reset tokens of user %s: %v", user.ID, err)
+		}
+	}
+	if changed && mail != nil {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("Could not send verification email to user %s: %v", user.ID, err)
+		}
+	}
+
+	recordAudit("update", "user", user.ID, user.ID, "changed own email")
+	respondWithUser(w, "User updated", user.ID)
+}
+
+// changePasswordHandler sets a new password for the authenticated user after
+// checking the current one. Every other session of the user is ended; the one
+// making the request stays logged in.
+func changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := helper.PrincipalFromContext(r.Context())
+
+	user, err := st.GetUser(principal.UserID)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var req PasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if !crypto.VerifyPassword(req.CurrentPassword, user.Password) {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+	if !checkPassword(w, req.NewPassword, user.Username, user.Email) {
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		helper.RespondWithError(w, http.StatusBadRequest, "New password must differ from the current one")
+		return
+	}
+
+	passwordHash, err := crypto.HashPassword(req.NewPassword)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not change password")
+		return
+	}
+
+	user.Password = passwordHash
+	user.MustChangePassword = false
+	if err := st.UpdateUser(user); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not change password")
+		return
+	}
+
+	ended := 0
+	for _, session := range st.ListUserSessions(user.ID) {
+		if session.ID == principal.SessionID || session.RevokedAt != nil {
+			continue
+		}
+		if err := endSession(session.ID); err != nil {
+			helper.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions")
+			return
+		}
+		ended++
+	}
+
+	recordAudit("change_password", "user", user.ID, user.ID, "changed own password")
+	helper.RespondWithSuccess(w, http.StatusOK, "Password changed", map[string]int{
+		"sessions_revoked": ended,
+	})
+}
+
+// emailTaken reports whether a user other than exceptID has email.
+func emailTaken(email string, exceptID string) bool {
+	user, err := st.GetUserByEmail(email)
+	return err == nil && user.ID != exceptID
+}
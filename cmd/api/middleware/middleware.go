@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"context"
 	"net/http"
 	"strings"
 	"sync"
@@ -10,65 +9,284 @@ import (
 	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
 	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
 	"github.com/C0d3-5t3w/aServ/internal/config"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
 	"github.com/C0d3-5t3w/aServ/internal/storage"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+// Error codes sent with 401 responses so that clients can tell a token that
+// has merely expired from one that will never be accepted.
+const (
+	ErrCodeAuthRequired     = "auth_required"
+	ErrCodeTokenInvalid     = "token_invalid"
+	ErrCodeTokenExpired     = "token_expired"
+	ErrCodeTokenNotYetValid = "token_not_yet_valid"
+	ErrCodeTokenRevoked     = "token_revoked"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodePasswordChange   = "password_change_required"
+	ErrCodeTwoFactorSetup   = "two_factor_setup_required"
+	ErrCodeEmailUnverified  = "email_verification_required"
+)
+
 var (
 	requestCounts  = make(map[string][]time.Time)
 	requestCountMu sync.Mutex
 )
 
-func AuthMiddleware(cfg *config.Config, st *storage.Storage) mux.MiddlewareFunc {
+// APIKeyHeader carries an API key. Keys are also accepted as bearer tokens,
+// recognised by crypto.APIKeyPrefix.
+const APIKeyHeader = "X-API-Key"
+
+// AuthMiddleware authenticates requests by their bearer token or API key and
+// attaches the resulting helper.Principal to the request context.
+func AuthMiddleware(cfg *config.Config, st storage.Store, keyring *crypto.Keyring) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+			apiKey := r.Header.Get(APIKeyHeader)
+			if apiKey == "" && strings.HasPrefix(authHeader, "Bearer "+crypto.APIKeyPrefix) {
+				apiKey = strings.TrimPrefix(authHeader, "Bearer ")
+			}
 
-			if authHeader == "" {
-				helper.RespondWithError(w, http.StatusUnauthorized, "Authorization header required")
+			var principal helper.Principal
+			var ok bool
+			if apiKey != "" {
+				principal, ok = authenticateAPIKey(w, cfg, st, apiKey)
+			} else {
+				principal, ok = authenticateToken(w, cfg, st, keyring, authHeader)
+			}
+			if !ok {
 				return
 			}
 
-			tokenParts := strings.Split(authHeader, "Bearer ")
-			if len(tokenParts) != 2 {
-				helper.RespondWithError(w, http.StatusUnauthorized, "Invalid token format")
-				return
+			if cfg.Features.Audit {
+				logAuditRequest(st, principal.UserID, r.Method, r.URL.Path)
 			}
 
-			token := tokenParts[1]
+			next.ServeHTTP(w, r.WithContext(helper.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// authenticateToken resolves the principal of an access token. It writes the
+// error response itself and reports false when the token is not accepted.
+func authenticateToken(w http.ResponseWriter, cfg *config.Config, st storage.Store, keyring *crypto.Keyring, authHeader string) (helper.Principal, bool) {
+	if authHeader == "" {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authorization header required")
+		return helper.Principal{}, false
+	}
+
+	tokenParts := strings.Split(authHeader, "Bearer ")
+	if len(tokenParts) != 2 {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenInvalid, "Invalid token format")
+		return helper.Principal{}, false
+	}
+
+	claims, err := crypto.ParseToken(tokenParts[1], keyring)
+	if err != nil {
+		RespondWithTokenError(w, err)
+		return helper.Principal{}, false
+	}
+	if claims.Purpose != "" {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenInvalid, "Invalid token")
+		return helper.Principal{}, false
+	}
+	if IsTokenRevoked(st, claims) || !CheckSession(st, claims) {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenRevoked, "Token has been revoked")
+		return helper.Principal{}, false
+	}
+
+	principal, ok := userPrincipal(w, cfg, st, claims.Subject)
+	if !ok {
+		return principal, false
+	}
+
+	principal.Scopes = strings.Fields(claims.Scope)
+	principal.TokenID = claims.ID
+	principal.SessionID = claims.SessionID
+	principal.ExpiresAt = claims.ExpiresAtTime()
+	return principal, true
+}
+
+// authenticateAPIKey resolves the principal of an API key, limited to the
+// key's scopes, and records that the key was used. It writes the error
+// response itself and reports false when the key is not accepted.
+func authenticateAPIKey(w http.ResponseWriter, cfg *config.Config, st storage.Store, apiKey string) (helper.Principal, bool) {
+	key, err := st.GetAPIKeyByHash(crypto.HashToken(apiKey))
+	if err != nil {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenInvalid, "Invalid API key")
+		return helper.Principal{}, false
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenRevoked, "API key has been revoked")
+		return helper.Principal{}, false
+	}
+	if !key.Active(now) {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenExpired, "API key has expired")
+		return helper.Principal{}, false
+	}
+
+	principal, ok := userPrincipal(w, cfg, st, key.UserID)
+	if !ok {
+		return principal, false
+	}
+
+	principal.Scopes = key.Scopes
+	principal.APIKeyID = key.ID
+	if key.ExpiresAt != nil {
+		principal.ExpiresAt = *key.ExpiresAt
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= sessionTouchInterval {
+		st.TouchAPIKey(key.ID, now)
+	}
+	return principal, true
+}
+
+// userPrincipal builds the principal for an enabled user with the permissions
+// of the user's role. It writes the error response itself and reports false
+// when the user may not authenticate.
+func userPrincipal(w http.ResponseWriter, cfg *config.Config, st storage.Store, userID string) (helper.Principal, bool) {
+	user, err := st.GetUser(userID)
+	if err != nil {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenInvalid, "User not found")
+		return helper.Principal{}, false
+	}
+	if user.Disabled() {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenRevoked, "Account is disabled")
+		return helper.Principal{}, false
+	}
+
+	// A role that no longer exists grants nothing rather than locking the
+	// user out of endpoints that need no permission.
+	permissions, _ := rbac.RolePermissions(st, user.Role)
+
+	mustEnroll := false
+	if cfg.RequiresTwoFactor(user.Role) {
+		tf, err := st.GetTwoFactor(user.ID)
+		mustEnroll = err != nil || !tf.Enabled()
+	}
+
+	// Without a mail driver there is no way to verify an address, so users
+	// left unverified from before it was turned off are not held back.
+	unverifiedAccess := ""
+	if user.MustVerifyEmail && cfg.Mail.Enabled() && cfg.UnverifiedAccessLevel() != config.UnverifiedFull {
+		unverifiedAccess = cfg.UnverifiedAccessLevel()
+	}
+
+	return helper.Principal{
+		UserID:              user.ID,
+		Role:                user.Role,
+		Permissions:         permissions,
+		MustChangePassword:  user.MustChangePassword,
+		MustEnrollTwoFactor: mustEnroll,
+		UnverifiedAccess:    unverifiedAccess,
+	}, true
+}
 
-			userID, err := crypto.Decode(token, cfg.Auth.Secret)
-			if err != nil {
-				helper.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+// RespondWithTokenError writes the 401 response for an error returned by
+// crypto.ParseToken.
+func RespondWithTokenError(w http.ResponseWriter, err error) {
+	switch err {
+	case crypto.ErrTokenExpired:
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenExpired, "Token has expired")
+	case crypto.ErrTokenNotYetValid:
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenNotYetValid, "Token is not valid yet")
+	default:
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeTokenInvalid, "Invalid token")
+	}
+}
+
+// IsTokenRevoked reports whether the token described by claims was revoked on
+// its own or by revoking every session of its user. A user revocation covers
+// tokens issued up to and including the millisecond of the revocation.
+func IsTokenRevoked(st storage.Store, claims crypto.Claims) bool {
+	if _, err := st.GetRevocation(storage.TokenRevocationID(claims.ID)); err == nil {
+		return true
+	}
+
+	revocation, err := st.GetRevocation(storage.UserRevocationID(claims.Subject))
+	return err == nil && claims.IssuedBefore(revocation.RevokedAt)
+}
+
+// sessionTouchInterval bounds how often the last-seen time of a session or
+// API key is written, so that busy clients do not turn every request into a
+// write.
+const sessionTouchInterval = time.Minute
+
+// CheckSession reports whether the session named by the token's sid claim is
+// still open and records activity on it. Tokens issued without a session are
+// accepted.
+func CheckSession(st storage.Store, claims crypto.Claims) bool {
+	if claims.SessionID == "" {
+		return true
+	}
+
+	session, err := st.GetSession(claims.SessionID)
+	if err != nil || session.UserID != claims.Subject || session.RevokedAt != nil {
+		return false
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		st.TouchSession(session.ID, now)
+	}
+	return true
+}
+
+// RequirePermission rejects requests whose principal may not use permission,
+// and every request of a principal that has to change its password or enroll
+// in two-factor authentication first. It must run after AuthMiddleware.
+func RequirePermission(permission string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := helper.PrincipalFromContext(r.Context())
+			if !ok {
+				helper.RespondWithErrorCode(w, http.StatusUnauthorized, ErrCodeAuthRequired, "Authentication required")
 				return
 			}
 
-			user, err := st.GetUser(userID)
-			if err != nil {
-				helper.RespondWithError(w, http.StatusUnauthorized, "User not found")
+			if principal.MustChangePassword {
+				helper.RespondWithErrorCode(w, http.StatusForbidden, ErrCodePasswordChange, "You must change your password first")
+				return
+			}
+			if principal.MustEnrollTwoFactor {
+				helper.RespondWithErrorCode(w, http.StatusForbidden, ErrCodeTwoFactorSetup, "Your role requires two-factor authentication; set it up under /api/me/2fa")
 				return
 			}
 
-			ctx := helper.SetUserContext(r.Context(), userID)
-			ctx = context.WithValue(ctx, "userRole", user.Role)
+			switch principal.UnverifiedAccess {
+			case config.UnverifiedNone:
+				helper.RespondWithErrorCode(w, http.StatusForbidden, ErrCodeEmailUnverified, "Verify your email address first")
+				return
+			case config.UnverifiedReadOnly:
+				if !rbac.IsRead(permission) {
+					helper.RespondWithErrorCode(w, http.StatusForbidden, ErrCodeEmailUnverified, "Verify your email address to make changes")
+					return
+				}
+			}
 
-			if cfg.Features.Audit {
-				path := r.URL.Path
-				logAuditRequest(st, userID, r.Method, path)
+			if !principal.Can(permission) {
+				helper.RespondWithErrorCode(w, http.StatusForbidden, ErrCodeForbidden, "Missing permission: "+permission)
+				return
 			}
 
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r)
 		})
 	}
 }
 
-func AdminMiddleware(next http.Handler) http.Handler {
+// SessionOnly rejects requests authenticated with an API key, for endpoints
+// that manage the user's login sessions and credentials. It must run after
+// AuthMiddleware.
+func SessionOnly(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		role, ok := r.Context().Value("userRole").(string)
-
-		if !ok || role != storage.RoleAdmin {
-			helper.RespondWithError(w, http.StatusForbidden, "Admin access required")
+		principal, _ := helper.PrincipalFromContext(r.Context())
+		if principal.APIKeyID != "" {
+			helper.RespondWithErrorCode(w, http.StatusForbidden, ErrCodeForbidden, "This endpoint cannot be used with an API key")
 			return
 		}
 
@@ -130,7 +348,7 @@ func isRateLimited(clientID string, maxRequestsPerMinute int) bool {
 	return len(requestCounts[clientID]) > maxRequestsPerMinute
 }
 
-func logAuditRequest(st *storage.Storage, userID, method, path string) {
+func logAuditRequest(st storage.Store, userID, method, path string) {
 	entity := "unknown"
 	entityID := ""
 	action := "access"
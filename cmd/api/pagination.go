@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+var (
+	itemFilters = []string{"category_id", "tag", "created_by", "min_price", "max_price", "q"}
+	userFilters = []string{"role", "q"}
+)
+
+// paginationFromQuery reads page, per_page, sort, order and the given filter
+// keys from the request's query string.
+func paginationFromQuery(r *http.Request, sortFields []string, filters []string) (storage.PaginationParams, error) {
+	query := r.URL.Query()
+	params := storage.PaginationParams{
+		Page:    1,
+		PerPage: storage.DefaultPerPage,
+		Filter:  map[string]string{},
+	}
+
+	if value := query.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return params, errors.New("Page must be a positive integer")
+		}
+		params.Page = page
+	}
+
+	if value := query.Get("per_page"); value != "" {
+		perPage, err := strconv.Atoi(value)
+		if err != nil || perPage < 1 || perPage > storage.MaxPerPage {
+			return params, errors.New("The per_page parameter must be between 1 and " + strconv.Itoa(storage.MaxPerPage))
+		}
+		params.PerPage = perPage
+	}
+
+	if value := query.Get("sort"); value != "" {
+		if !contains(sortFields, value) {
+			return params, errors.New("Invalid sort field: " + value)
+		}
+		params.SortBy = value
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		params.SortDesc = true
+	default:
+		return params, errors.New("Order must be asc or desc")
+	}
+
+	for _, key := range filters {
+		value := query.Get(key)
+		if value == "" {
+			continue
+		}
+
+		if key == "min_price" || key == "max_price" {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return params, errors.New("The " + key + " parameter must be a number")
+			}
+		}
+		params.Filter[key] = value
+	}
+
+	return params, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+)
+
+const (
+	errCodePasswordPolicy = "password_policy"
+	errCodeUsernamePolicy = "username_policy"
+)
+
+// checkPassword responds with every rule of the password policy that
+// password breaks for the account with username and email, and returns
+// false if there are any.
+func checkPassword(w http.ResponseWriter, password, username, email string) bool {
+	violations := accountPolicy.CheckPassword(password, username, email)
+	if len(violations) == 0 {
+		return true
+	}
+
+	helper.RespondWithErrorDetails(w, http.StatusBadRequest, errCodePasswordPolicy, "Password does not meet the password policy", violations)
+	return false
+}
+
+// checkUsername is checkPassword for the username policy.
+func checkUsername(w http.ResponseWriter, username string) bool {
+	violations := accountPolicy.CheckUsername(username)
+	if len(violations) == 0 {
+		return true
+	}
+
+	helper.RespondWithErrorDetails(w, http.StatusBadRequest, errCodeUsernamePolicy, "Username does not meet the username policy", violations)
+	return false
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+var roleNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+func listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	helper.RespondWithSuccess(w, http.StatusOK, "Roles retrieved", st.ListRoles())
+}
+
+func getRoleHandler(w http.ResponseWriter, r *http.Request) {
+	role, err := st.GetRole(mux.Vars(r)["name"])
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Role not found")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Role retrieved", role)
+}
+
+func createRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if !roleNameRegex.MatchString(req.Name) {
+		helper.RespondWithError(w, http.StatusBadRequest, "Role name must be 2-32 lowercase letters, digits, '-' or '_'")
+		return
+	}
+	if err := rbac.Validate(req.Permissions); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := st.GetRole(req.Name); err == nil {
+		helper.RespondWithError(w, http.StatusConflict, "Role already exists")
+		return
+	}
+
+	now := time.Now()
+	role := storage.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: rolePermissions(req.Permissions),
+		Source:      storage.RoleSourceAPI,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := st.CreateRole(role); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create role")
+		return
+	}
+
+	userID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("create", "role", role.Name, userID, "created role")
+	helper.RespondWithSuccess(w, http.StatusCreated, "Role created", role)
+}
+
+func updateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	role, ok := editableRole(w, r)
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := rbac.Validate(req.Permissions); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	role.Description = req.Description
+	role.Permissions = rolePermissions(req.Permissions)
+
+	if err := st.UpdateRole(role); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not update role")
+		return
+	}
+
+	role, _ = st.GetRole(role.Name)
+	userID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("update", "role", role.Name, userID, "updated role")
+	helper.RespondWithSuccess(w, http.StatusOK, "Role updated", role)
+}
+
+func deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	role, ok := editableRole(w, r)
+	if !ok {
+		return
+	}
+
+	if err := st.DeleteRole(role.Name); err != nil {
+		if err == storage.ErrRoleInUse {
+			helper.RespondWithError(w, http.StatusConflict, "Role is still assigned to users")
+			return
+		}
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not delete role")
+		return
+	}
+
+	userID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("delete", "role", role.Name, userID, "deleted role")
+	helper.RespondWithSuccess(w, http.StatusOK, "Role deleted", nil)
+}
+
+// editableRole resolves the role named in a /roles/{name} route and checks
+// that it may be changed through the API. Built-in roles are fixed and config
+// roles are changed in the config file. It writes the error response itself
+// and reports false when the request cannot proceed.
+func editableRole(w http.ResponseWriter, r *http.Request) (storage.Role, bool) {
+	role, err := st.GetRole(mux.Vars(r)["name"])
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Role not found")
+		return storage.Role{}, false
+	}
+
+	if role.Source != storage.RoleSourceAPI {
+		helper.RespondWithError(w, http.StatusConflict, "Role is defined by "+role.Source+" and cannot be changed through the API")
+		return storage.Role{}, false
+	}
+
+	return role, true
+}
+
+// rolePermissions keeps a role without permissions from being stored and
+// returned as null.
+func rolePermissions(permissions []string) []string {
+	if permissions == nil {
+		return []string{}
+	}
+	return permissions
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SessionResponse is a session as shown to its owner, flagging the one the
+// request was made with.
+type SessionResponse struct {
+	storage.Session
+	Current bool `json:"current"`
+}
+
+func listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := helper.PrincipalFromContext(r.Context())
+
+	now := time.Now()
+	sessions := []SessionResponse{}
+	for _, session := range st.ListUserSessions(principal.UserID) {
+		if !session.Active(now) {
+			continue
+		}
+		sessions = append(sessions, SessionResponse{
+			Session: session,
+			Current: session.ID == principal.SessionID,
+		})
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Sessions retrieved", sessions)
+}
+
+func revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	session, err := st.GetSession(id)
+	if err != nil || session.UserID != userID {
+		helper.RespondWithError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if err := endSession(id); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not revoke session")
+		return
+	}
+
+	recordAudit("revoke_session", "session", id, userID, "revoked session from "+session.IP)
+	helper.RespondWithSuccess(w, http.StatusOK, "Session revoked", nil)
+}
+
+// startSession records a new login by user from the client making r.
+func startSession(r *http.Request, user storage.User) (storage.Session, error) {
+	now := time.Now()
+	session := storage.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		UserAgent:  r.UserAgent(),
+		IP:         clientIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL()),
+	}
+
+	return session, st.CreateSession(session)
+}
+
+// endSession revokes a session together with its refresh token family. Its
+// access tokens are rejected from then on by the session check in the auth
+// middleware.
+func endSession(id string) error {
+	if err := st.RevokeSession(id); err != nil {
+		return err
+	}
+	return st.RevokeRefreshTokenFamily(id)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+func TestRevokeSessionEndsItsTokens(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.createUser("alice", storage.RoleUser)
+
+	phone := srv.login("alice")
+	laptop := srv.login("alice")
+	token := laptop.field("token")
+
+	res := srv.call("GET", "/api/auth/sessions", token, nil)
+	expect(t, "list sessions", res, http.StatusOK)
+	sessions, _ := res.Data.([]interface{})
+	if len(sessions) != 2 {
+		t.Fatalf("listed %d sessions, want 2", len(sessions))
+	}
+
+	// The phone's session is the one the laptop's token was not issued in.
+	var phoneSession string
+	for _, s := range sessions {
+		session, _ := s.(map[string]interface{})
+		if current, _ := session["current"].(bool); !current {
+			phoneSession, _ = session["id"].(string)
+		}
+	}
+	if phoneSession == "" {
+		t.Fatalf("no other session in %v", sessions)
+	}
+
+	expect(t, "revoke session", srv.call("DELETE", "/api/auth/sessions/"+phoneSession, token, nil), http.StatusOK)
+
+	expect(t, "access token of the revoked session", srv.call("GET", "/api/me", phone.field("token"), nil), http.StatusUnauthorized)
+	refresh := srv.call("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: phone.field("refresh_token")})
+	expect(t, "refresh token of the revoked session", refresh, http.StatusUnauthorized)
+
+	expect(t, "access token of the revoking session", srv.call("GET", "/api/me", token, nil), http.StatusOK)
+	refresh = srv.call("POST", "/api/auth/refresh", "", RefreshRequest{RefreshToken: laptop.field("refresh_token")})
+	expect(t, "refresh token of the revoking session", refresh, http.StatusOK)
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const maxTagNameLength = 50
+
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+func listTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags := st.ListTags()
+	helper.RespondWithSuccess(w, http.StatusOK, "Tags retrieved", tags)
+}
+
+func getTagHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	tag, err := st.GetTag(id)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Tag retrieved", tag)
+}
+
+func createTagHandler(w http.ResponseWriter, r *http.Request) {
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	name, ok := validTagName(w, req.Name)
+	if !ok {
+		return
+	}
+
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	tag := storage.Tag{
+		ID:        uuid.New().String(),
+		Name:      name,
+		CreatedAt: time.Now(),
+		CreatedBy: userID,
+	}
+
+	err := st.CreateTag(tag)
+	if errors.Is(err, storage.ErrTagNameTaken) {
+		helper.RespondWithError(w, http.StatusConflict, "Tag already exists")
+		return
+	}
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create tag")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusCreated, "Tag created", tag)
+}
+
+func updateTagHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	existingTag, err := st.GetTag(id)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	name, ok := validTagName(w, req.Name)
+	if !ok {
+		return
+	}
+
+	if !canModify(r, existingTag.CreatedBy, rbac.TagsManage) {
+		helper.RespondWithError(w, http.StatusForbidden, "You don't have permission to rename this tag")
+		return
+	}
+
+	existingTag.Name = name
+
+	err = st.UpdateTag(existingTag)
+	if errors.Is(err, storage.ErrTagNameTaken) {
+		helper.RespondWithError(w, http.StatusConflict, "Tag already exists")
+		return
+	}
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not update tag")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Tag updated", existingTag)
+}
+
+func deleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	existingTag, err := st.GetTag(id)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	if !canModify(r, existingTag.CreatedBy, rbac.TagsManage) {
+		helper.RespondWithError(w, http.StatusForbidden, "You don't have permission to delete this tag")
+		return
+	}
+
+	if err := st.DeleteTag(id); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not delete tag")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Tag deleted", nil)
+}
+
+func getTagItemsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if _, err := st.GetTag(id); err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	items := st.GetItemsByTag(id)
+	helper.RespondWithSuccess(w, http.StatusOK, "Tag items retrieved", items)
+}
+
+func attachItemTagHandler(w http.ResponseWriter, r *http.Request) {
+	item, tagID, ok := itemTagTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := st.AddItemTag(item.ID, tagID); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not tag item")
+		return
+	}
+
+	item, _ = st.GetItem(item.ID)
+	helper.RespondWithSuccess(w, http.StatusOK, "Tag attached", item)
+}
+
+func detachItemTagHandler(w http.ResponseWriter, r *http.Request) {
+	item, tagID, ok := itemTagTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := st.RemoveItemTag(item.ID, tagID); err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Tag is not attached to this item")
+		return
+	}
+
+	item, _ = st.GetItem(item.ID)
+	helper.RespondWithSuccess(w, http.StatusOK, "Tag detached", item)
+}
+
+// itemTagTarget resolves the item and tag named in an /items/{id}/tags/{tagId}
+// route and checks that the caller may change the item's tags. It writes the
+// error response itself and reports false when the request cannot proceed.
+func itemTagTarget(w http.ResponseWriter, r *http.Request) (storage.Item, string, bool) {
+	vars := mux.Vars(r)
+
+	item, err := st.GetItem(vars["id"])
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return storage.Item{}, "", false
+	}
+
+	tag, err := st.GetTag(vars["tagId"])
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Tag not found")
+		return storage.Item{}, "", false
+	}
+
+	if !canModify(r, item.CreatedBy, rbac.ItemsManage) {
+		helper.RespondWithError(w, http.StatusForbidden, "You don't have permission to change this item's tags")
+		return storage.Item{}, "", false
+	}
+
+	return item, tag.ID, true
+}
+
+func validTagName(w http.ResponseWriter, name string) (string, bool) {
+	name = helper.NormalizeTagName(name)
+
+	if name == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Tag name is required")
+		return "", false
+	}
+	if len(name) > maxTagNameLength {
+		helper.RespondWithError(w, http.StatusBadRequest, "Tag name is too long")
+		return "", false
+	}
+	return name, true
+}
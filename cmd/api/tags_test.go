@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+func TestTagNameConflict(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.createUser("alice", storage.RoleUser)
+	token := srv.login("alice").field("token")
+
+	created := srv.call("POST", "/api/tags", token, TagRequest{Name: "On Sale"})
+	expect(t, "create on sale", created, http.StatusCreated)
+	fresh := srv.call("POST", "/api/tags", token, TagRequest{Name: "New"})
+	expect(t, "create new", fresh, http.StatusCreated)
+
+	expect(t, "create ON  SALE", srv.call("POST", "/api/tags", token, TagRequest{Name: " ON  SALE "}), http.StatusConflict)
+	expect(t, "rename new to on sale", srv.call("PUT", "/api/tags/"+fresh.field("id"), token, TagRequest{Name: "On sale"}), http.StatusConflict)
+	expect(t, "rename on sale to itself", srv.call("PUT", "/api/tags/"+created.field("id"), token, TagRequest{Name: "on sale"}), http.StatusOK)
+}
@@ -0,0 +1,363 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/cmd/api/middleware"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+const (
+	// twoFactorChallengeTTL is how long a user has between entering their
+	// password and entering their second factor.
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+)
+
+// TwoFactorRequest carries a second factor: either a TOTP code or, when the
+// authenticator is lost, one of the recovery codes.
+type TwoFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	TwoFactorRequest
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+	TwoFactorRequest
+}
+
+func getTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := helper.PrincipalFromContext(r.Context())
+
+	status := map[string]interface{}{
+		"enabled":                  false,
+		"pending":                  false,
+		"required":                 cfg.RequiresTwoFactor(principal.Role),
+		"recovery_codes_remaining": 0,
+	}
+	if tf, err := st.GetTwoFactor(principal.UserID); err == nil {
+		status["enabled"] = tf.Enabled()
+		status["pending"] = !tf.Enabled()
+		status["recovery_codes_remaining"] = len(tf.RecoveryCodes)
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Two-factor status retrieved", status)
+}
+
+// setupTwoFactorHandler starts an enrollment with a fresh secret, replacing
+// any enrollment that was started but never enabled. Two-factor
+// authentication stays off until enableTwoFactorHandler sees a valid code.
+func setupTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	user, err := st.GetUser(userID)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if tf, err := st.GetTwoFactor(userID); err == nil && tf.Enabled() {
+		helper.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := crypto.NewTOTPSecret()
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not set up two-factor authentication")
+		return
+	}
+
+	tf := storage.TwoFactor{
+		UserID:        userID,
+		Secret:        secret,
+		RecoveryCodes: []string{},
+		CreatedAt:     time.Now(),
+	}
+	if err := st.PutTwoFactor(tf); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not set up two-factor authentication")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Scan the provisioning URI, then confirm with a code", map[string]string{
+		"secret":           secret,
+		"provisioning_uri": crypto.TOTPProvisioningURI(cfg.AppName, user.Username, secret),
+	})
+}
+
+// enableTwoFactorHandler turns on a pending enrollment once the user sends a
+// code from their authenticator, and returns the recovery codes, which are
+// not shown again.
+func enableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	var req TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	tf, err := st.GetTwoFactor(userID)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusConflict, "Set up two-factor authentication first")
+		return
+	}
+	if tf.Enabled() {
+		helper.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	if !useTOTPCode(tf, req.Code) {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	codes, err := resetRecoveryCodes(userID, true)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not enable two-factor authentication")
+		return
+	}
+
+	recordAudit("enable_2fa", "user", userID, userID, "enabled two-factor authentication")
+	helper.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication enabled; store the recovery codes safely", map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// disableTwoFactorHandler removes the user's enrollment after checking both
+// their password and a second factor. Users whose role requires two-factor
+// authentication cannot turn it off.
+func disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := helper.PrincipalFromContext(r.Context())
+
+	var req TwoFactorDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if cfg.RequiresTwoFactor(principal.Role) {
+		helper.RespondWithError(w, http.StatusForbidden, "Your role requires two-factor authentication")
+		return
+	}
+
+	user, err := st.GetUser(principal.UserID)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	tf, err := st.GetTwoFactor(user.ID)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Two-factor authentication is not set up")
+		return
+	}
+
+	if !crypto.VerifyPassword(req.Password, user.Password) {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+	if tf.Enabled() && !useSecondFactor(tf, req.TwoFactorRequest) {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	if err := st.DeleteTwoFactor(user.ID); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not disable two-factor authentication")
+		return
+	}
+
+	recordAudit("disable_2fa", "user", user.ID, user.ID, "disabled two-factor authentication")
+	helper.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// regenerateRecoveryCodesHandler replaces every recovery code of the user,
+// after checking a TOTP code.
+func regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := helper.GetUserFromContext(r.Context())
+
+	var req TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	tf, err := st.GetTwoFactor(userID)
+	if err != nil || !tf.Enabled() {
+		helper.RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return
+	}
+	if !useTOTPCode(tf, req.Code) {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	codes, err := resetRecoveryCodes(userID, false)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create recovery codes")
+		return
+	}
+
+	recordAudit("regenerate_recovery_codes", "user", userID, userID, "replaced recovery codes")
+	helper.RespondWithSuccess(w, http.StatusOK, "Recovery codes replaced", map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// verifyTwoFactorHandler completes a login that loginHandler answered with a
+// challenge token. Each challenge can complete one login.
+func verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChallengeToken == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Challenge token is required")
+		return
+	}
+
+	claims, err := crypto.ParseToken(req.ChallengeToken, keyring)
+	if err != nil {
+		middleware.RespondWithTokenError(w, err)
+		return
+	}
+	if claims.Purpose != crypto.PurposeTwoFactor || middleware.IsTokenRevoked(st, claims) {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, middleware.ErrCodeTokenInvalid, "Invalid challenge token")
+		return
+	}
+
+	user, err := st.GetUser(claims.Subject)
+	if err != nil || user.Disabled() {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, middleware.ErrCodeTokenInvalid, "Invalid challenge token")
+		return
+	}
+	tf, err := st.GetTwoFactor(user.ID)
+	if err != nil || !tf.Enabled() {
+		helper.RespondWithErrorCode(w, http.StatusUnauthorized, middleware.ErrCodeTokenInvalid, "Invalid challenge token")
+		return
+	}
+
+	if !checkLoginLockout(w, r, user.Username) {
+		return
+	}
+
+	if !useSecondFactor(tf, req.TwoFactorRequest) {
+		recordLoginFailure(r, user.Username, user.ID, "invalid second factor")
+		helper.RespondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	err = st.PutRevocation(storage.Revocation{
+		ID:        storage.TokenRevocationID(claims.ID),
+		UserID:    user.ID,
+		TokenID:   claims.ID,
+		RevokedAt: time.Now(),
+		ExpiresAt: claims.ExpiresAtTime(),
+	})
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not complete login")
+		return
+	}
+
+	clearLoginFailures(user.Username)
+
+	session, err := startSession(r, user)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not create session")
+		return
+	}
+
+	tokens, err := issueTokens(user, session.ID, "")
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	helper.RespondWithSuccess(w, http.StatusOK, "Login successful", tokens)
+}
+
+// resetUserTwoFactorHandler lets an admin remove the enrollment of a user who
+// lost both their authenticator and their recovery codes. If the user's role
+// requires two-factor authentication they have to enroll again.
+func resetUserTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := st.DeleteTwoFactor(user.ID); err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "Two-factor authentication is not set up")
+		return
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("reset_2fa", "user", user.ID, adminID, "removed two-factor authentication")
+	helper.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication removed", nil)
+}
+
+// twoFactorChallenge returns the data of a login response for a user with
+// two-factor authentication: a challenge token to send to
+// /api/auth/2fa/verify along with the second factor.
+func twoFactorChallenge(user storage.User) (map[string]interface{}, error) {
+	claims := crypto.NewClaims(user.ID, user.Role, twoFactorChallengeTTL)
+	claims.Purpose = crypto.PurposeTwoFactor
+
+	token, err := crypto.IssueToken(claims, keyring)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"two_factor_required": true,
+		"challenge_token":     token,
+		"expires_at":          claims.ExpiresAtTime(),
+	}, nil
+}
+
+// useSecondFactor checks and consumes the TOTP code or recovery code in req.
+func useSecondFactor(tf storage.TwoFactor, req TwoFactorRequest) bool {
+	if req.RecoveryCode != "" {
+		hash := crypto.HashToken(crypto.NormalizeRecoveryCode(req.RecoveryCode))
+		return st.UseRecoveryCode(tf.UserID, hash) == nil
+	}
+	return useTOTPCode(tf, req.Code)
+}
+
+// useTOTPCode checks code against tf's secret and marks its time step as
+// used, so that an observed code cannot be replayed.
+func useTOTPCode(tf storage.TwoFactor, code string) bool {
+	step, ok := crypto.VerifyTOTP(tf.Secret, code, time.Now())
+	return ok && st.UseTwoFactorStep(tf.UserID, step) == nil
+}
+
+// resetRecoveryCodes stores new recovery codes for userID, enabling the
+// enrollment as well when enable is set, and returns the codes.
+func resetRecoveryCodes(userID string, enable bool) ([]string, error) {
+	codes, err := crypto.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	tf, err := st.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tf.RecoveryCodes = make([]string, len(codes))
+	for i, code := range codes {
+		tf.RecoveryCodes[i] = crypto.HashToken(code)
+	}
+	if enable {
+		now := time.Now()
+		tf.EnabledAt = &now
+	}
+
+	if err := st.PutTwoFactor(tf); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
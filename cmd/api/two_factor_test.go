@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+// enrollTwoFactor turns on two-factor authentication for the user holding
+// token and returns the TOTP secret, the code used to confirm it and the
+// recovery codes.
+func enrollTwoFactor(t *testing.T, srv *testServer, token string) (string, string, []string) {
+	t.Helper()
+
+	setup := srv.call("POST", "/api/me/2fa/setup", token, nil)
+	expect(t, "2fa setup", setup, http.StatusOK)
+	secret := setup.field("secret")
+
+	code, err := crypto.TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	enabled := srv.call("POST", "/api/me/2fa/enable", token, TwoFactorRequest{Code: code})
+	expect(t, "2fa enable", enabled, http.StatusOK)
+
+	var recoveryCodes []string
+	list, _ := enabled.Data.(map[string]interface{})["recovery_codes"].([]interface{})
+	for _, c := range list {
+		recoveryCodes = append(recoveryCodes, c.(string))
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(recoveryCodes), recoveryCodeCount)
+	}
+	return secret, code, recoveryCodes
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.createUser("alice", storage.RoleUser)
+	secret, enrollCode, recoveryCodes := enrollTwoFactor(t, srv, srv.login("alice").field("token"))
+
+	verify := func(challenge string, req TwoFactorRequest) testResponse {
+		return srv.call("POST", "/api/auth/2fa/verify", "", TwoFactorVerifyRequest{ChallengeToken: challenge, TwoFactorRequest: req})
+	}
+
+	login := srv.login("alice")
+	if login.field("token") != "" {
+		t.Fatal("login issued an access token before the second factor")
+	}
+	challenge := login.field("challenge_token")
+	if challenge == "" {
+		t.Fatalf("login returned no challenge: %v", login.Data)
+	}
+	expect(t, "challenge as access token", srv.call("GET", "/api/me", challenge, nil), http.StatusUnauthorized)
+
+	// The code that confirmed the enrollment has been used up.
+	expect(t, "enrollment code", verify(challenge, TwoFactorRequest{Code: enrollCode}), http.StatusUnauthorized)
+	expect(t, "wrong recovery code", verify(challenge, TwoFactorRequest{RecoveryCode: "AAAAA-AAAAA"}), http.StatusUnauthorized)
+
+	next, err := crypto.TOTPCode(secret, time.Now().Add(30*time.Second))
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	verified := verify(challenge, TwoFactorRequest{Code: next})
+	expect(t, "verify", verified, http.StatusOK)
+	expect(t, "access token after verify", srv.call("GET", "/api/me", verified.field("token"), nil), http.StatusOK)
+
+	// Neither the challenge nor the code completes a second login.
+	expect(t, "used challenge", verify(challenge, TwoFactorRequest{RecoveryCode: recoveryCodes[0]}), http.StatusUnauthorized)
+	expect(t, "used code", verify(srv.login("alice").field("challenge_token"), TwoFactorRequest{Code: next}), http.StatusUnauthorized)
+
+	expect(t, "recovery code", verify(srv.login("alice").field("challenge_token"), TwoFactorRequest{RecoveryCode: recoveryCodes[0]}), http.StatusOK)
+	expect(t, "used recovery code", verify(srv.login("alice").field("challenge_token"), TwoFactorRequest{RecoveryCode: recoveryCodes[0]}), http.StatusUnauthorized)
+	expect(t, "another recovery code", verify(srv.login("alice").field("challenge_token"), TwoFactorRequest{RecoveryCode: recoveryCodes[1]}), http.StatusOK)
+}
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
+	"github.com/C0d3-5t3w/aServ/cmd/api/helper"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+const errCodeAccountDisabled = "account_disabled"
+
+type UserUpdateRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type UserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type PasswordResetRequest struct {
+	Password string `json:"password"`
+}
+
+// updateUserHandler lets an admin change another user's username and email.
+// Fields left empty keep their current value.
+func updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req UserUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Username != "" && req.Username != user.Username {
+		if !checkUsername(w, req.Username) {
+			return
+		}
+		if _, err := st.GetUserByUsername(req.Username); err == nil {
+			helper.RespondWithError(w, http.StatusConflict, "Username already taken")
+			return
+		}
+		user.Username = req.Username
+	}
+	if req.Email != "" {
+		if !helper.ValidateEmail(req.Email) {
+			helper.RespondWithError(w, http.StatusBadRequest, "Invalid email format")
+			return
+		}
+		if emailTaken(req.Email, user.ID) {
+			helper.RespondWithError(w, http.StatusConflict, "Email already in use")
+			return
+		}
+		user.Email = req.Email
+	}
+
+	if err := st.UpdateUser(user); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not update user")
+		return
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("update", "user", user.ID, adminID, "updated user profile")
+	respondWithUser(w, "User updated", user.ID)
+}
+
+// deleteUserHandler deletes a user. What happens to the user's items,
+// categories, tags and audit log entries must be chosen explicitly, either
+// with ?reassign_to=<user id> or with ?cascade=true.
+func deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	reassignTo := r.URL.Query().Get("reassign_to")
+	cascade := r.URL.Query().Get("cascade") == "true"
+	if (reassignTo == "") == !cascade {
+		helper.RespondWithError(w, http.StatusBadRequest, "Specify either reassign_to or cascade=true")
+		return
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	if user.ID == adminID {
+		helper.RespondWithError(w, http.StatusConflict, "You cannot delete your own account")
+		return
+	}
+
+	if reassignTo != "" {
+		if reassignTo == user.ID {
+			helper.RespondWithError(w, http.StatusBadRequest, "Cannot reassign content to the user being deleted")
+			return
+		}
+		if _, err := st.GetUser(reassignTo); err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, "Reassignment user not found")
+			return
+		}
+	}
+
+	if err := st.DeleteUser(user.ID, reassignTo); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not delete user")
+		return
+	}
+
+	details := "deleted user and their content"
+	if reassignTo != "" {
+		details = "deleted user, content reassigned to " + reassignTo
+	}
+	recordAudit("delete", "user", user.ID, adminID, details)
+	helper.RespondWithSuccess(w, http.StatusOK, "User deleted", nil)
+}
+
+func updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req UserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Role is required")
+		return
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	if user.ID == adminID {
+		helper.RespondWithError(w, http.StatusConflict, "You cannot change your own role")
+		return
+	}
+
+	if err := st.UpdateUserRole(user.ID, req.Role); err != nil {
+		if err.Error() == "invalid role" {
+			helper.RespondWithError(w, http.StatusBadRequest, "Role not found")
+			return
+		}
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not update role")
+		return
+	}
+
+	recordAudit("update_role", "user", user.ID, adminID, "role changed from "+user.Role+" to "+req.Role)
+	respondWithUser(w, "Role updated", user.ID)
+}
+
+// disableUserHandler blocks a user from logging in and ends all of their
+// sessions. The account and its content are kept.
+func disableUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	if user.ID == adminID {
+		helper.RespondWithError(w, http.StatusConflict, "You cannot disable your own account")
+		return
+	}
+
+	if !user.Disabled() {
+		now := time.Now()
+		user.DisabledAt = &now
+		if err := st.UpdateUser(user); err != nil {
+			helper.RespondWithError(w, http.StatusInternalServerError, "Could not disable user")
+			return
+		}
+	}
+
+	if err := revokeUserSessions(user.ID); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions")
+		return
+	}
+
+	recordAudit("disable", "user", user.ID, adminID, "disabled account")
+	respondWithUser(w, "User disabled", user.ID)
+}
+
+func enableUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.Disabled() {
+		user.DisabledAt = nil
+		if err := st.UpdateUser(user); err != nil {
+			helper.RespondWithError(w, http.StatusInternalServerError, "Could not enable user")
+			return
+		}
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("enable", "user", user.ID, adminID, "enabled account")
+	respondWithUser(w, "User enabled", user.ID)
+}
+
+// resetUserPasswordHandler sets a new password for a user and ends all of
+// their sessions. Without a password in the body a random one is generated
+// and returned once, for the admin to hand over. Either way the user has to
+// change it on next login.
+func resetUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	password := req.Password
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = crypto.NewOpaqueToken(); err != nil {
+			helper.RespondWithError(w, http.StatusInternalServerError, "Could not reset password")
+			return
+		}
+	} else if !checkPassword(w, password, user.Username, user.Email) {
+		return
+	}
+
+	passwordHash, err := crypto.HashPassword(password)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not reset password")
+		return
+	}
+
+	user.Password = passwordHash
+	user.MustChangePassword = true
+	if err := st.UpdateUser(user); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not reset password")
+		return
+	}
+
+	if err := revokeUserSessions(user.ID); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions")
+		return
+	}
+
+	adminID, _ := helper.GetUserFromContext(r.Context())
+	recordAudit("reset_password", "user", user.ID, adminID, "password reset by admin")
+
+	var data interface{}
+	if generated {
+		data = map[string]string{"temporary_password": password}
+	}
+	helper.RespondWithSuccess(w, http.StatusOK, "Password reset", data)
+}
+
+// targetUser resolves the user named in a /users/{id} route. It writes the
+// error response itself and reports false when the user does not exist.
+func targetUser(w http.ResponseWriter, r *http.Request) (storage.User, bool) {
+	user, err := st.GetUser(mux.Vars(r)["id"])
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "User not found")
+		return storage.User{}, false
+	}
+	return user, true
+}
+
+// manageableUser is targetUser for actions that would let the caller take
+// over or shut out the account, such as resetting its password or deleting
+// it. It refuses with 403 when the target's role grants a permission the
+// caller lacks, so that users:manage cannot be used against an admin.
+func manageableUser(w http.ResponseWriter, r *http.Request) (storage.User, bool) {
+	user, ok := targetUser(w, r)
+	if !ok {
+		return storage.User{}, false
+	}
+
+	principal, _ := helper.PrincipalFromContext(r.Context())
+	granted, _ := rbac.RolePermissions(st, user.Role)
+	for _, permission := range rbac.AllPermissions {
+		if rbac.Allows(granted, permission) && !principal.Can(permission) {
+			helper.RespondWithError(w, http.StatusForbidden, "You cannot manage a user with permissions you do not have")
+			return storage.User{}, false
+		}
+	}
+	return user, true
+}
+
+// respondWithUser responds with the stored state of user id, without its
+// password hash.
+func respondWithUser(w http.ResponseWriter, message string, id string) {
+	user, err := st.GetUser(id)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	user.Password = ""
+	helper.RespondWithSuccess(w, http.StatusOK, message, user)
+}
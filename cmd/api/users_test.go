@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/C0d3-5t3w/aServ/internal/config"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+func TestUserManagerCannotActOnAdmin(t *testing.T) {
+	srv := newTestServer(t, func(c *config.Config) {
+		c.Roles = []config.RoleConfig{
+			{Name: "support", Permissions: []string{rbac.UsersRead, rbac.UsersManage}},
+			{Name: "auditor", Permissions: []string{rbac.UsersRead}},
+		}
+	})
+	admin := srv.createUser("alice", storage.RoleAdmin)
+	srv.createUser("sam", "support")
+	member := srv.createUser("mallory", "auditor")
+	token := srv.login("sam").field("token")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{"update", "PUT", "/api/users/" + admin.ID},
+		{"delete", "DELETE", "/api/users/" + admin.ID + "?cascade=true"},
+		{"disable", "POST", "/api/users/" + admin.ID + "/disable"},
+		{"reset password", "POST", "/api/users/" + admin.ID + "/reset-password"},
+		{"reset 2fa", "DELETE", "/api/users/" + admin.ID + "/2fa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, tt.name, srv.call(tt.method, tt.path, token, map[string]string{}), http.StatusForbidden)
+		})
+	}
+
+	if user, err := st.GetUser(admin.ID); err != nil || user.Disabled() {
+		t.Fatalf("admin after refused actions: %+v, %v", user, err)
+	}
+	srv.login("alice")
+
+	// Users whose role grants nothing beyond the caller's stay manageable.
+	expect(t, "disable user", srv.call("POST", "/api/users/"+member.ID+"/disable", token, nil), http.StatusOK)
+	expect(t, "delete user", srv.call("DELETE", "/api/users/"+member.ID+"?cascade=true", token, nil), http.StatusOK)
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/C0d3-5t3w/aServ/cmd/api"
+	"github.com/C0d3-5t3w/aServ/cmd/api/crypto"
 	"github.com/C0d3-5t3w/aServ/cmd/api/dashboard"
 	"github.com/C0d3-5t3w/aServ/internal/config"
+	"github.com/C0d3-5t3w/aServ/internal/mailer"
+	"github.com/C0d3-5t3w/aServ/internal/policy"
+	"github.com/C0d3-5t3w/aServ/internal/rbac"
 	"github.com/C0d3-5t3w/aServ/internal/storage"
 	"github.com/gorilla/mux"
 )
@@ -16,15 +26,75 @@ import (
 func main() {
 	log.Println("Starting aServ application...")
 
-	cfg := config.LoadConfig()
+	configPath := flag.String("config", config.DefaultPath, "path to the YAML configuration file")
+	dataDir := flag.String("data-dir", "", "directory holding the storage files; overrides the directory of storage.path")
+	flag.Parse()
+
+	cfg := config.LoadConfig(*configPath)
 	log.Printf("Loaded configuration for: %s", cfg.AppName)
+	if err := cfg.CheckProduction(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
+	rules, err := policy.New(cfg)
+	if err != nil {
+		log.Fatalf("Invalid account policy: %v", err)
+	}
+
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Invalid mail settings: %v", err)
+	}
+
+	keyring, err := api.NewKeyring(cfg)
+	if err != nil {
+		log.Fatalf("Invalid signing keys: %v", err)
+	}
+	go reloadKeysOnHangup(*configPath, keyring)
 
-	st := storage.NewStorage()
-	log.Println("Storage initialized")
+	opts := storage.Options{
+		Path:     cfg.Storage.Path,
+		Backend:  cfg.Storage.Driver,
+		ReadOnly: cfg.Storage.ReadOnly,
+	}
+	if *dataDir != "" {
+		if opts.Path == "" {
+			opts.Path = storage.DefaultPath(opts.Backend)
+		}
+		opts.Path = filepath.Join(*dataDir, filepath.Base(opts.Path))
+
+		if err := os.MkdirAll(*dataDir, 0755); err != nil {
+			log.Fatalf("Could not create data directory %s: %v", *dataDir, err)
+		}
+	}
+
+	st, err := storage.NewStorage(opts)
+	if err != nil {
+		log.Fatalf("Could not open storage at %s: %v", opts.Path, err)
+	}
+	defer st.Close()
+	log.Printf("Storage initialized (%s)", opts.Path)
+	if opts.ReadOnly {
+		log.Println("Storage is read-only")
+	}
+
+	if !opts.ReadOnly {
+		if err := rbac.SyncRoles(st, cfg.Roles); err != nil {
+			log.Fatalf("Could not set up roles: %v", err)
+		}
+		created, err := api.BootstrapAdmin(cfg, st)
+		if err != nil {
+			log.Fatalf("Could not create admin user: %v", err)
+		}
+		if created {
+			log.Printf("Created admin user %s; the password must be changed on first login", cfg.Admin.DefaultUsername)
+		}
+		go pruneExpiredTokens(st, cfg.Lockout)
+	}
 
 	router := mux.NewRouter().StrictSlash(true)
 
-	api.RegisterRoutes(router, cfg, st)
+	api.RegisterRoutes(router, cfg, st, keyring, mail, rules)
 	log.Println("API routes registered")
 
 	dashboard.Routes(router)
@@ -38,6 +108,86 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+
+		log.Println("Shutting down...")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
 	log.Printf("Server starting on %s", server.Addr)
-	log.Fatal(server.ListenAndServe())
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// reloadKeysOnHangup re-reads the signing keys from the config file whenever
+// the process receives SIGHUP, so keys can be rotated without a restart. A
+// file that fails to load or validate leaves the current keys in place.
+func reloadKeysOnHangup(configPath string, keyring *crypto.Keyring) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		cfg, err := config.ReadConfig(configPath)
+		if err != nil {
+			log.Println("Could not reload signing keys:", err)
+			continue
+		}
+		if err := cfg.CheckProduction(); err != nil {
+			log.Println("Could not reload signing keys:", err)
+			continue
+		}
+		if err := api.ReloadKeyring(keyring, cfg); err != nil {
+			log.Println("Could not reload signing keys:", err)
+			continue
+		}
+		log.Printf("Reloaded signing keys, active key %s", keyring.Signing().ID)
+	}
+}
+
+// pruneExpiredTokens periodically drops refresh tokens, revocations,
+// sessions, email tokens and login attempts that no longer have any effect,
+// so that none of these collections grows without bound.
+func pruneExpiredTokens(st storage.Store, lockout config.LockoutConfig) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+
+		if n, err := st.DeleteExpiredRefreshTokens(now); err != nil {
+			log.Println("Could not prune expired refresh tokens:", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d expired refresh tokens", n)
+		}
+
+		if n, err := st.DeleteExpiredRevocations(now); err != nil {
+			log.Println("Could not prune expired revocations:", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d expired revocations", n)
+		}
+
+		if n, err := st.DeleteExpiredSessions(now); err != nil {
+			log.Println("Could not prune expired sessions:", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d expired sessions", n)
+		}
+
+		if n, err := st.DeleteExpiredEmailTokens(now); err != nil {
+			log.Println("Could not prune expired email tokens:", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d expired email tokens", n)
+		}
+
+		if n, err := st.DeleteExpiredLoginAttempts(now.Add(-lockout.Retention())); err != nil {
+			log.Println("Could not prune login attempts:", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d login attempts", n)
+		}
+	}
 }
@@ -1,27 +1,42 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	AppName  string `yaml:"app_name"`
-	Port     string `yaml:"port"`
-	LogLevel string `yaml:"log_level"`
-	Auth     struct {
-		Secret    string `yaml:"secret"`
-		ExpireHrs int    `yaml:"expire_hrs"`
+	AppName     string `yaml:"app_name"`
+	Environment string `yaml:"environment"`
+	Port        string `yaml:"port"`
+	LogLevel    string `yaml:"log_level"`
+	Auth        struct {
+		Secret           string       `yaml:"secret"`
+		ActiveKey        string       `yaml:"active_key"`
+		Keys             []SigningKey `yaml:"keys"`
+		ExpireHrs        int          `yaml:"expire_hrs"`
+		RefreshExpireHrs int          `yaml:"refresh_expire_hrs"`
+		Require2FARoles  []string     `yaml:"require_2fa_roles"`
+		UnverifiedAccess string       `yaml:"unverified_access"`
+		VerifyExpireHrs  int          `yaml:"verify_expire_hrs"`
+		ResetExpireMins  int          `yaml:"reset_expire_mins"`
 	} `yaml:"auth"`
 	Storage struct {
-		Path string `yaml:"path"`
+		Driver   string `yaml:"driver"`
+		Path     string `yaml:"path"`
+		ReadOnly bool   `yaml:"read_only"`
 	} `yaml:"storage"`
 	RateLimit struct {
 		Enabled   bool `yaml:"enabled"`
 		MaxPerMin int  `yaml:"max_per_min"`
 	} `yaml:"rate_limit"`
+	Lockout  LockoutConfig `yaml:"lockout"`
+	Mail     MailConfig    `yaml:"mail"`
 	Features struct {
 		Analytics    bool `yaml:"analytics"`
 		ImageUploads bool `yaml:"image_uploads"`
@@ -32,42 +47,270 @@ type Config struct {
 		DefaultPassword string `yaml:"default_password"`
 		DefaultEmail    string `yaml:"default_email"`
 	} `yaml:"admin"`
+	PasswordPolicy PasswordPolicy `yaml:"password_policy"`
+	UsernamePolicy UsernamePolicy `yaml:"username_policy"`
+	Roles          []RoleConfig   `yaml:"roles"`
 }
 
-func LoadConfig() *Config {
-	cfg := &Config{}
+// SigningKey is one entry of auth.keys. Keys other than the active one only
+// verify existing tokens, and stop doing so at RetireAt when it is set.
+type SigningKey struct {
+	ID       string    `yaml:"id"`
+	Secret   string    `yaml:"secret"`
+	RetireAt time.Time `yaml:"retire_at"`
+}
+
+// RoleConfig is one entry of roles, defining a role in addition to the
+// built-in admin and user roles.
+type RoleConfig struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Permissions []string `yaml:"permissions"`
+}
+
+// LockoutConfig is the lockout section. Once a username or client IP reaches
+// its number of failed logins within the window, further logins from it are
+// refused for the base delay, doubling with every further failure up to the
+// maximum delay.
+type LockoutConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	MaxAttempts   int  `yaml:"max_attempts"`
+	MaxIPAttempts int  `yaml:"max_ip_attempts"`
+	BaseDelaySecs int  `yaml:"base_delay_secs"`
+	MaxDelayMins  int  `yaml:"max_delay_mins"`
+	WindowMins    int  `yaml:"window_mins"`
+}
+
+// Window is how long a failed login keeps counting towards a lockout.
+func (l LockoutConfig) Window() time.Duration {
+	if l.WindowMins <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(l.WindowMins) * time.Minute
+}
+
+// Delay is how long logins are refused after the given number of failures,
+// with threshold the number of failures allowed before the first lockout.
+func (l LockoutConfig) Delay(failures, threshold int) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+
+	base := time.Duration(l.BaseDelaySecs) * time.Second
+	if base <= 0 {
+		base = 30 * time.Second
+	}
+	limit := l.maxDelay()
+
+	delay := base
+	for i := threshold; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// Retention is how long after its last failure a login attempt can still
+// matter: the longest lockout followed by the counting window.
+func (l LockoutConfig) Retention() time.Duration {
+	return l.maxDelay() + l.Window()
+}
+
+func (l LockoutConfig) maxDelay() time.Duration {
+	if l.MaxDelayMins <= 0 {
+		return time.Hour
+	}
+	return time.Duration(l.MaxDelayMins) * time.Minute
+}
+
+// MailConfig is the mail section. Driver "smtp" sends through an SMTP
+// server and "spool" writes each message to a file in SpoolDir; without a
+// driver no mail is sent, which also turns off email verification and
+// password resets. Links in messages start with BaseURL.
+type MailConfig struct {
+	Driver       string `yaml:"driver"`
+	From         string `yaml:"from"`
+	BaseURL      string `yaml:"base_url"`
+	SpoolDir     string `yaml:"spool_dir"`
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+}
+
+// Enabled reports whether a mail driver is configured.
+func (m MailConfig) Enabled() bool {
+	return m.Driver != ""
+}
+
+// PasswordPolicy is the password_policy section. Lengths count characters;
+// a zero length means the default of 8 for MinLength and 128 for MaxLength.
+// Passwords from the bundled list of common passwords, and passwords that
+// contain the username or email address, are refused unless allowed.
+type PasswordPolicy struct {
+	MinLength         int  `yaml:"min_length"`
+	MaxLength         int  `yaml:"max_length"`
+	RequireUpper      bool `yaml:"require_upper"`
+	RequireLower      bool `yaml:"require_lower"`
+	RequireDigit      bool `yaml:"require_digit"`
+	RequireSymbol     bool `yaml:"require_symbol"`
+	AllowCommon       bool `yaml:"allow_common"`
+	AllowPersonalInfo bool `yaml:"allow_personal_info"`
+}
+
+// UsernamePolicy is the username_policy section. A zero length means the
+// default of 3 for MinLength and 20 for MaxLength, and an empty Pattern
+// allows letters, digits and underscores. Reserved names are compared
+// without regard to case.
+type UsernamePolicy struct {
+	MinLength int      `yaml:"min_length"`
+	MaxLength int      `yaml:"max_length"`
+	Pattern   string   `yaml:"pattern"`
+	Reserved  []string `yaml:"reserved"`
+}
+
+const DefaultPath = "./pkg/config/config.yaml"
+
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
+)
+
+// Values of auth.unverified_access, which decides what users may do before
+// they have verified their email address: everything, only what needs no
+// more than a ":read" permission, or only what needs no permission at all.
+const (
+	UnverifiedFull     = "full"
+	UnverifiedReadOnly = "read_only"
+	UnverifiedNone     = "none"
+)
+
+// defaultAdminPassword is the admin password shipped in the default config,
+// which must not be used to bootstrap a production server.
+const defaultAdminPassword = "adminpass"
+
+// placeholderSecrets are the signing secrets shipped in the default config and
+// the sample config file. Anyone can forge tokens signed with them.
+var placeholderSecrets = []string{
+	"",
+	"default-secret-change-me",
+	"replace-with-your-secret-key",
+	"replace-with-a-new-secret",
+}
+
+// IsProduction reports whether the server runs in production mode. Any other
+// environment, including none, is treated as development.
+func (c *Config) IsProduction() bool {
+	return c.Environment == EnvProduction
+}
+
+// RequiresTwoFactor reports whether users with role must enroll in two-factor
+// authentication before they can use any endpoint that needs a permission.
+func (c *Config) RequiresTwoFactor(role string) bool {
+	for _, r := range c.Auth.Require2FARoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
 
-	configFile, err := ioutil.ReadFile("./pkg/config/config.yaml")
+// UnverifiedAccessLevel returns auth.unverified_access, defaulting to
+// UnverifiedReadOnly when it is unset or unknown.
+func (c *Config) UnverifiedAccessLevel() string {
+	switch c.Auth.UnverifiedAccess {
+	case UnverifiedFull, UnverifiedNone:
+		return c.Auth.UnverifiedAccess
+	default:
+		return UnverifiedReadOnly
+	}
+}
+
+// CheckProduction returns an error describing the first setting that is unsafe
+// to run with in production. Outside production it always returns nil.
+func (c *Config) CheckProduction() error {
+	if !c.IsProduction() {
+		return nil
+	}
+	if c.Admin.DefaultPassword == "" || c.Admin.DefaultPassword == defaultAdminPassword {
+		return errors.New("admin.default_password must be changed from its default in production")
+	}
+	if len(c.Auth.Keys) == 0 && isPlaceholderSecret(c.Auth.Secret) {
+		return errors.New("auth.secret must be changed from its default in production")
+	}
+	for _, key := range c.Auth.Keys {
+		if isPlaceholderSecret(key.Secret) {
+			return fmt.Errorf("auth.keys entry %q must have its secret changed from the default in production", key.ID)
+		}
+	}
+	return nil
+}
+
+func isPlaceholderSecret(secret string) bool {
+	for _, placeholder := range placeholderSecrets {
+		if secret == placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+func LoadConfig(path string) *Config {
+	cfg, err := ReadConfig(path)
 	if err != nil {
-		log.Println("Warning: Could not read config file, using defaults:", err)
+		log.Println("Warning: Could not load config file, using defaults:", err)
 		return getDefaultConfig()
 	}
+	return cfg
+}
+
+// ReadConfig is LoadConfig without the fallback to defaults, for reloading a
+// running server where a broken file must not replace the current settings.
+func ReadConfig(path string) (*Config, error) {
+	cfg := &Config{}
 
-	err = yaml.Unmarshal(configFile, cfg)
+	configFile, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Println("Warning: Could not parse config file, using defaults:", err)
-		return getDefaultConfig()
+		return nil, err
 	}
 
-	return cfg
+	if err := yaml.Unmarshal(configFile, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
 }
 
 func getDefaultConfig() *Config {
 	return &Config{
-		AppName:  "aServ",
-		Port:     "8080",
-		LogLevel: "info",
+		AppName:     "aServ",
+		Environment: EnvDevelopment,
+		Port:        "8080",
+		LogLevel:    "info",
 		Auth: struct {
-			Secret    string `yaml:"secret"`
-			ExpireHrs int    `yaml:"expire_hrs"`
+			Secret           string       `yaml:"secret"`
+			ActiveKey        string       `yaml:"active_key"`
+			Keys             []SigningKey `yaml:"keys"`
+			ExpireHrs        int          `yaml:"expire_hrs"`
+			RefreshExpireHrs int          `yaml:"refresh_expire_hrs"`
+			Require2FARoles  []string     `yaml:"require_2fa_roles"`
+			UnverifiedAccess string       `yaml:"unverified_access"`
+			VerifyExpireHrs  int          `yaml:"verify_expire_hrs"`
+			ResetExpireMins  int          `yaml:"reset_expire_mins"`
 		}{
-			Secret:    "default-secret-change-me",
-			ExpireHrs: 24,
+			Secret:           "default-secret-change-me",
+			ExpireHrs:        24,
+			RefreshExpireHrs: 720,
+			UnverifiedAccess: UnverifiedReadOnly,
+			VerifyExpireHrs:  48,
+			ResetExpireMins:  60,
 		},
 		Storage: struct {
-			Path string `yaml:"path"`
+			Driver   string `yaml:"driver"`
+			Path     string `yaml:"path"`
+			ReadOnly bool   `yaml:"read_only"`
 		}{
-			Path: "./pkg/storage/storage.json",
+			Driver: "json",
+			Path:   "./pkg/storage/storage.json",
 		},
 		RateLimit: struct {
 			Enabled   bool `yaml:"enabled"`
@@ -76,6 +319,19 @@ func getDefaultConfig() *Config {
 			Enabled:   true,
 			MaxPerMin: 60,
 		},
+		Lockout: LockoutConfig{
+			Enabled:       true,
+			MaxAttempts:   5,
+			MaxIPAttempts: 20,
+			BaseDelaySecs: 30,
+			MaxDelayMins:  60,
+			WindowMins:    15,
+		},
+		Mail: MailConfig{
+			From:     "aServ <noreply@localhost>",
+			BaseURL:  "http://localhost:8080",
+			SpoolDir: "./pkg/mail",
+		},
 		Features: struct {
 			Analytics    bool `yaml:"analytics"`
 			ImageUploads bool `yaml:"image_uploads"`
@@ -91,8 +347,17 @@ func getDefaultConfig() *Config {
 			DefaultEmail    string `yaml:"default_email"`
 		}{
 			DefaultUsername: "admin",
-			DefaultPassword: "adminpass",
+			DefaultPassword: defaultAdminPassword,
 			DefaultEmail:    "admin@example.com",
 		},
+		PasswordPolicy: PasswordPolicy{
+			MinLength: 8,
+			MaxLength: 128,
+		},
+		UsernamePolicy: UsernamePolicy{
+			MinLength: 3,
+			MaxLength: 20,
+			Reserved:  []string{"admin", "administrator", "api", "root", "system", "support", "dashboard"},
+		},
 	}
 }
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDelay(t *testing.T) {
+	defaults := LockoutConfig{}
+	custom := LockoutConfig{BaseDelaySecs: 10, MaxDelayMins: 1}
+
+	tests := []struct {
+		name      string
+		lockout   LockoutConfig
+		failures  int
+		threshold int
+		want      time.Duration
+	}{
+		{"no failures", defaults, 0, 5, 0},
+		{"below threshold", defaults, 4, 5, 0},
+		{"at threshold", defaults, 5, 5, 30 * time.Second},
+		{"one over", defaults, 6, 5, time.Minute},
+		{"three over", defaults, 8, 5, 4 * time.Minute},
+		{"capped at default max", defaults, 12, 5, time.Hour},
+		{"far past the cap", defaults, 1000, 5, time.Hour},
+		{"zero threshold disables", defaults, 100, 0, 0},
+		{"negative threshold disables", defaults, 100, -1, 0},
+		{"custom base", custom, 3, 3, 10 * time.Second},
+		{"custom doubling", custom, 5, 3, 40 * time.Second},
+		{"custom cap", custom, 6, 3, time.Minute},
+		{"base above cap", LockoutConfig{BaseDelaySecs: 600, MaxDelayMins: 1}, 1, 1, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lockout.Delay(tt.failures, tt.threshold); got != tt.want {
+				t.Errorf("Delay(%d, %d) = %v, want %v", tt.failures, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockoutRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		lockout LockoutConfig
+		want    time.Duration
+	}{
+		{"defaults", LockoutConfig{}, time.Hour + 15*time.Minute},
+		{"custom", LockoutConfig{MaxDelayMins: 5, WindowMins: 10}, 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lockout.Retention(); got != tt.want {
+				t.Errorf("Retention() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckProduction(t *testing.T) {
+	production := func(change func(*Config)) *Config {
+		cfg := getDefaultConfig()
+		cfg.Environment = EnvProduction
+		cfg.Admin.DefaultPassword = "a-strong-admin-password"
+		cfg.Auth.Secret = "a-strong-signing-secret"
+		change(cfg)
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"defaults in development", getDefaultConfig(), false},
+		{"safe production", production(func(*Config) {}), false},
+		{"default admin password", production(func(c *Config) { c.Admin.DefaultPassword = defaultAdminPassword }), true},
+		{"empty admin password", production(func(c *Config) { c.Admin.DefaultPassword = "" }), true},
+		{"built-in secret", production(func(c *Config) { c.Auth.Secret = "default-secret-change-me" }), true},
+		{"sample file secret", production(func(c *Config) { c.Auth.Secret = "replace-with-your-secret-key" }), true},
+		{"empty secret", production(func(c *Config) { c.Auth.Secret = "" }), true},
+		{"secret unused with keys", production(func(c *Config) {
+			c.Auth.Secret = ""
+			c.Auth.Keys = []SigningKey{{ID: "2026-10", Secret: "a-strong-key-secret"}}
+		}), false},
+		{"sample key secret", production(func(c *Config) {
+			c.Auth.Keys = []SigningKey{
+				{ID: "2026-10", Secret: "a-strong-key-secret"},
+				{ID: "default", Secret: "replace-with-a-new-secret"},
+			}
+		}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.CheckProduction(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckProduction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package mailer sends the emails the API needs, such as email verification
+// and password reset links.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/internal/config"
+)
+
+// Message is a plain text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns the mailer selected by cfg.Driver, or nil when no driver is
+// configured.
+func New(cfg config.MailConfig) (Mailer, error) {
+	if cfg.From == "" && cfg.Enabled() {
+		return nil, errors.New("mail.from is required")
+	}
+
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case "smtp":
+		return NewSMTPMailer(cfg)
+	case "spool":
+		return NewSpoolMailer(cfg.SpoolDir, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// format renders msg as an RFC 5322 message from from, returning it together
+// with the ID it was given.
+func format(from string, msg Message) ([]byte, string, error) {
+	id, err := messageID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var b bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, stripNewlines(value))
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+id+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return b.Bytes(), id, nil
+}
+
+func messageID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + "@aserv", nil
+}
+
+// stripNewlines keeps header values on one line, so that no value can add
+// headers of its own.
+func stripNewlines(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
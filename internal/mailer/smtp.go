@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+
+	"github.com/C0d3-5t3w/aServ/internal/config"
+)
+
+// SMTPMailer sends messages through an SMTP server, authenticating with PLAIN
+// when a username is configured. net/smtp upgrades to TLS when the server
+// offers STARTTLS and refuses to authenticate over an unencrypted connection
+// to anything but localhost.
+type SMTPMailer struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(cfg config.MailConfig) (*SMTPMailer, error) {
+	if cfg.SMTPHost == "" {
+		return nil, errors.New("mail.smtp_host is required for the smtp driver")
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid mail.from: %w", err)
+	}
+
+	port := cfg.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port)),
+		host: cfg.SMTPHost,
+		from: cfg.From,
+	}
+	if cfg.SMTPUsername != "" {
+		m.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	data, _, err := format(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, from.Address, []string{msg.To}, data)
+}
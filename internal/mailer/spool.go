@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SpoolMailer writes every message to its own .eml file in a directory
+// instead of sending it, for development and for setups where another
+// process delivers the spool.
+type SpoolMailer struct {
+	dir  string
+	from string
+}
+
+func NewSpoolMailer(dir string, from string) (*SpoolMailer, error) {
+	if dir == "" {
+		dir = "./pkg/mail"
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &SpoolMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message under a temporary name first, so that a process
+// watching the directory never sees a partial .eml file.
+func (m *SpoolMailer) Send(msg Message) error {
+	data, id, err := format(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	name := time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + id[:8] + ".eml"
+	tmp := filepath.Join(m.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(m.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Package policy checks passwords and usernames against the rules in the
+// password_policy and username_policy config sections.
+package policy
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/C0d3-5t3w/aServ/internal/config"
+)
+
+// Rules a password or username can violate.
+const (
+	RuleMinLength        = "min_length"
+	RuleMaxLength        = "max_length"
+	RuleRequireUpper     = "require_upper"
+	RuleRequireLower     = "require_lower"
+	RuleRequireDigit     = "require_digit"
+	RuleRequireSymbol    = "require_symbol"
+	RuleCommon           = "common_password"
+	RuleContainsUsername = "contains_username"
+	RuleContainsEmail    = "contains_email"
+	RulePattern          = "pattern"
+	RuleReserved         = "reserved"
+)
+
+const defaultUsernamePattern = `^[a-zA-Z0-9_]+$`
+
+// personalInfoMinLength is the shortest username or email local part that a
+// password may not contain, so that a user called "al" can still use
+// "totally-secret".
+const personalInfoMinLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// Violation is one rule that a password or username breaks.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Policy holds the configured password and username rules.
+type Policy struct {
+	password        config.PasswordPolicy
+	username        config.UsernamePolicy
+	usernamePattern *regexp.Regexp
+	reserved        map[string]bool
+	common          map[string]bool
+}
+
+// New builds the policy described by cfg, filling in defaults for unset
+// lengths. It fails on an invalid username pattern or on lengths that no
+// value could satisfy.
+func New(cfg *config.Config) (*Policy, error) {
+	p := &Policy{
+		password: cfg.PasswordPolicy,
+		username: cfg.UsernamePolicy,
+		reserved: make(map[string]bool),
+		common:   make(map[string]bool),
+	}
+
+	if p.password.MinLength <= 0 {
+		p.password.MinLength = 8
+	}
+	if p.password.MaxLength <= 0 {
+		p.password.MaxLength = 128
+	}
+	if p.password.MinLength > p.password.MaxLength {
+		return nil, fmt.Errorf("password_policy.min_length %d exceeds max_length %d", p.password.MinLength, p.password.MaxLength)
+	}
+
+	if p.username.MinLength <= 0 {
+		p.username.MinLength = 3
+	}
+	if p.username.MaxLength <= 0 {
+		p.username.MaxLength = 20
+	}
+	if p.username.MinLength > p.username.MaxLength {
+		return nil, fmt.Errorf("username_policy.min_length %d exceeds max_length %d", p.username.MinLength, p.username.MaxLength)
+	}
+
+	pattern := p.username.Pattern
+	if pattern == "" {
+		pattern = defaultUsernamePattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid username_policy.pattern: %w", err)
+	}
+	p.usernamePattern = re
+
+	for _, name := range p.username.Reserved {
+		p.reserved[strings.ToLower(name)] = true
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			p.common[strings.ToLower(line)] = true
+		}
+	}
+
+	return p, nil
+}
+
+// CheckPassword returns every rule that password breaks for the account with
+// username and email, or nil if it is acceptable.
+func (p *Policy) CheckPassword(password, username, email string) []Violation {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	rules := p.password
+	length := utf8.RuneCountInString(password)
+	if length < rules.MinLength {
+		add(RuleMinLength, "Password must be at least %d characters", rules.MinLength)
+	}
+	if length > rules.MaxLength {
+		add(RuleMaxLength, "Password must be at most %d characters", rules.MaxLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if rules.RequireUpper && !upper {
+		add(RuleRequireUpper, "Password must contain an uppercase letter")
+	}
+	if rules.RequireLower && !lower {
+		add(RuleRequireLower, "Password must contain a lowercase letter")
+	}
+	if rules.RequireDigit && !digit {
+		add(RuleRequireDigit, "Password must contain a digit")
+	}
+	if rules.RequireSymbol && !symbol {
+		add(RuleRequireSymbol, "Password must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if !rules.AllowCommon && p.common[lowered] {
+		add(RuleCommon, "Password is too common")
+	}
+
+	if !rules.AllowPersonalInfo {
+		if containsPart(lowered, username) {
+			add(RuleContainsUsername, "Password must not contain the username")
+		}
+		local, _, _ := strings.Cut(email, "@")
+		if containsPart(lowered, local) {
+			add(RuleContainsEmail, "Password must not contain the email address")
+		}
+	}
+
+	return violations
+}
+
+// CheckUsername returns every rule that username breaks, or nil if it is
+// acceptable.
+func (p *Policy) CheckUsername(username string) []Violation {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	rules := p.username
+	length := utf8.RuneCountInString(username)
+	if length < rules.MinLength {
+		add(RuleMinLength, "Username must be at least %d characters", rules.MinLength)
+	}
+	if length > rules.MaxLength {
+		add(RuleMaxLength, "Username must be at most %d characters", rules.MaxLength)
+	}
+	if !p.usernamePattern.MatchString(username) {
+		add(RulePattern, "Username contains characters that are not allowed")
+	}
+	if p.reserved[strings.ToLower(username)] {
+		add(RuleReserved, "Username is reserved")
+	}
+
+	return violations
+}
+
+// containsPart reports whether lowered contains part, ignoring parts too
+// short to be worth refusing.
+func containsPart(lowered, part string) bool {
+	if utf8.RuneCountInString(part) < personalInfoMinLength {
+		return false
+	}
+	return strings.Contains(lowered, strings.ToLower(part))
+}
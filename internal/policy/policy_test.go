@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/C0d3-5t3w/aServ/internal/config"
+)
+
+func newPolicy(t *testing.T, password config.PasswordPolicy, username config.UsernamePolicy) *Policy {
+	t.Helper()
+
+	p, err := New(&config.Config{PasswordPolicy: password, UsernamePolicy: username})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+func rules(violations []Violation) []string {
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		password config.PasswordPolicy
+		username config.UsernamePolicy
+	}{
+		{"password min above max", config.PasswordPolicy{MinLength: 20, MaxLength: 10}, config.UsernamePolicy{}},
+		{"password min above default max", config.PasswordPolicy{MinLength: 200}, config.UsernamePolicy{}},
+		{"username min above max", config.PasswordPolicy{}, config.UsernamePolicy{MinLength: 10, MaxLength: 5}},
+		{"invalid pattern", config.PasswordPolicy{}, config.UsernamePolicy{Pattern: "[a-z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(&config.Config{PasswordPolicy: tt.password, UsernamePolicy: tt.username}); err == nil {
+				t.Error("New succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	strict := config.PasswordPolicy{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name     string
+		policy   config.PasswordPolicy
+		password string
+		username string
+		email    string
+		want     []string
+	}{
+		{"acceptable", config.PasswordPolicy{}, "correct horse battery", "alice", "alice@example.com", nil},
+		{"too short", config.PasswordPolicy{}, "Xq7#pz", "alice", "", []string{RuleMinLength}},
+		{"short by bytes but not runes", config.PasswordPolicy{MinLength: 4}, "ééé", "alice", "", []string{RuleMinLength}},
+		{"too long", config.PasswordPolicy{MaxLength: 10}, "a-much-longer-passphrase", "alice", "", []string{RuleMaxLength}},
+		{"common", config.PasswordPolicy{}, "password", "alice", "", []string{RuleCommon}},
+		{"common in other case", config.PasswordPolicy{}, "PassWord", "alice", "", []string{RuleCommon}},
+		{"common allowed", config.PasswordPolicy{AllowCommon: true}, "password", "alice", "", nil},
+		{"contains username", config.PasswordPolicy{}, "xx-Alice-2024", "alice", "", []string{RuleContainsUsername}},
+		{"contains email local part", config.PasswordPolicy{}, "wonderland-9", "bob", "wonderland@example.com", []string{RuleContainsEmail}},
+		{"short username ignored", config.PasswordPolicy{}, "totally-secret", "al", "", nil},
+		{"personal info allowed", config.PasswordPolicy{AllowPersonalInfo: true}, "xx-alice-2024", "alice", "", nil},
+		{"strict satisfied", strict, "Tr0ub4dor&3", "alice", "", nil},
+		{"strict missing classes", strict, "lowercase only", "alice", "", []string{RuleRequireUpper, RuleRequireDigit, RuleRequireSymbol}},
+		{"strict missing lower", strict, "UPPER-CASE-1", "alice", "", []string{RuleRequireLower}},
+		{"space is not a symbol", strict, "Upper lower 1", "alice", "", []string{RuleRequireSymbol}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPolicy(t, tt.policy, config.UsernamePolicy{})
+			if got := rules(p.CheckPassword(tt.password, tt.username, tt.email)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckPassword(%q) rules = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckUsername(t *testing.T) {
+	reserved := config.UsernamePolicy{Reserved: []string{"admin", "Root"}}
+
+	tests := []struct {
+		name     string
+		policy   config.UsernamePolicy
+		username string
+		want     []string
+	}{
+		{"acceptable", config.UsernamePolicy{}, "alice_1", nil},
+		{"too short", config.UsernamePolicy{}, "al", []string{RuleMinLength}},
+		{"too long", config.UsernamePolicy{}, strings.Repeat("a", 21), []string{RuleMaxLength}},
+		{"default pattern", config.UsernamePolicy{}, "alice.smith", []string{RulePattern}},
+		{"custom pattern", config.UsernamePolicy{Pattern: `^[a-z.]+$`}, "alice.smith", nil},
+		{"custom pattern refuses", config.UsernamePolicy{Pattern: `^[a-z.]+$`}, "Alice", []string{RulePattern}},
+		{"custom lengths", config.UsernamePolicy{MinLength: 1, MaxLength: 4}, "alice", []string{RuleMaxLength}},
+		{"reserved", reserved, "admin", []string{RuleReserved}},
+		{"reserved in other case", reserved, "ROOT", []string{RuleReserved}},
+		{"several rules", reserved, "a!", []string{RuleMinLength, RulePattern}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPolicy(t, config.PasswordPolicy{}, tt.policy)
+			if got := rules(p.CheckUsername(tt.username)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckUsername(%q) rules = %v, want %v", tt.username, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package rbac defines the permissions that API routes require and the roles
+// that grant them.
+package rbac
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/C0d3-5t3w/aServ/internal/config"
+	"github.com/C0d3-5t3w/aServ/internal/storage"
+)
+
+// Permissions are named "<resource>:<action>". A role may also grant "*" for
+// every permission or "<resource>:*" for every action on one resource.
+const (
+	ItemsRead        = "items:read"
+	ItemsWrite       = "items:write"
+	ItemsManage      = "items:manage"
+	CategoriesRead   = "categories:read"
+	CategoriesWrite  = "categories:write"
+	CategoriesManage = "categories:manage"
+	TagsRead         = "tags:read"
+	TagsWrite        = "tags:write"
+	TagsManage       = "tags:manage"
+	UsersRead        = "users:read"
+	UsersManage      = "users:manage"
+	AuditRead        = "audit:read"
+	AnalyticsRead    = "analytics:read"
+	AnalyticsWrite   = "analytics:write"
+	ImagesWrite      = "images:write"
+	RolesManage      = "roles:manage"
+)
+
+// Wildcard grants every permission.
+const Wildcard = "*"
+
+// AllPermissions lists every permission a route can require. The ":write"
+// permissions cover creating resources and changing one's own; ":manage"
+// covers changing resources created by other users.
+var AllPermissions = []string{
+	ItemsRead, ItemsWrite, ItemsManage,
+	CategoriesRead, CategoriesWrite, CategoriesManage,
+	TagsRead, TagsWrite, TagsManage,
+	UsersRead, UsersManage,
+	AuditRead,
+	AnalyticsRead, AnalyticsWrite,
+	ImagesWrite,
+	RolesManage,
+}
+
+// BuiltinRoles are the roles every installation has. They are recreated at
+// startup if missing and cannot be changed through the API or the config.
+var BuiltinRoles = []storage.Role{
+	{
+		Name:        storage.RoleAdmin,
+		Description: "Full access to every resource",
+		Permissions: []string{Wildcard},
+	},
+	{
+		Name:        storage.RoleUser,
+		Description: "Reads everything and manages the resources it created",
+		Permissions: []string{
+			ItemsRead, ItemsWrite,
+			CategoriesRead, CategoriesWrite,
+			TagsRead, TagsWrite,
+			UsersRead,
+			AnalyticsRead,
+			ImagesWrite,
+		},
+	},
+}
+
+// Allows reports whether the granted permissions include permission, either
+// directly or through a wildcard.
+func Allows(granted []string, permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, g := range granted {
+		if g == permission || g == Wildcard || g == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRead reports whether permission only allows reading.
+func IsRead(permission string) bool {
+	return strings.HasSuffix(permission, ":read")
+}
+
+// Validate checks that every entry of permissions is a known permission or a
+// wildcard over a known resource.
+func Validate(permissions []string) error {
+	for _, p := range permissions {
+		if !isKnown(p) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+	}
+	return nil
+}
+
+func isKnown(permission string) bool {
+	if permission == Wildcard {
+		return true
+	}
+
+	resource, action, _ := strings.Cut(permission, ":")
+	for _, known := range AllPermissions {
+		if known == permission || (action == "*" && strings.HasPrefix(known, resource+":")) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBuiltin reports whether name is one of BuiltinRoles.
+func IsBuiltin(name string) bool {
+	_, ok := builtinRole(name)
+	return ok
+}
+
+func builtinRole(name string) (storage.Role, bool) {
+	for _, role := range BuiltinRoles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+	return storage.Role{}, false
+}
+
+// RolePermissions returns the permissions granted by the named role. Users
+// without a role get the permissions of storage.RoleUser, and the built-in
+// definitions are used when the store has no copy of a built-in role, as with
+// a read-only store that was never synced.
+func RolePermissions(st storage.Store, name string) ([]string, error) {
+	if name == "" {
+		name = storage.RoleUser
+	}
+
+	role, err := st.GetRole(name)
+	if err == nil {
+		return role.Permissions, nil
+	}
+	if builtin, ok := builtinRole(name); ok {
+		return builtin.Permissions, nil
+	}
+	return nil, err
+}
+
+// SyncRoles stores the built-in roles and the roles from the configuration
+// file. Config roles overwrite earlier config or API roles of the same name;
+// a config role that is no longer listed is kept, so users assigned to it do
+// not lose their role, until an admin deletes it through the API.
+func SyncRoles(st storage.Store, roles []config.RoleConfig) error {
+	now := time.Now()
+
+	for _, builtin := range BuiltinRoles {
+		builtin.Source = storage.RoleSourceBuiltin
+		builtin.CreatedAt = now
+		builtin.UpdatedAt = now
+		if err := putRole(st, builtin); err != nil {
+			return err
+		}
+	}
+
+	for _, rc := range roles {
+		if rc.Name == "" {
+			return fmt.Errorf("config role without a name")
+		}
+		if IsBuiltin(rc.Name) {
+			return fmt.Errorf("config role %q redefines a built-in role", rc.Name)
+		}
+		if err := Validate(rc.Permissions); err != nil {
+			return fmt.Errorf("config role %q: %w", rc.Name, err)
+		}
+
+		role := storage.Role{
+			Name:        rc.Name,
+			Description: rc.Description,
+			Permissions: rc.Permissions,
+			Source:      storage.RoleSourceConfig,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := putRole(st, role); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putRole creates role or replaces the stored role of the same name, keeping
+// its creation time. An unchanged role is left alone.
+func putRole(st storage.Store, role storage.Role) error {
+	existing, err := st.GetRole(role.Name)
+	if err != nil {
+		return st.CreateRole(role)
+	}
+
+	if existing.Description == role.Description && existing.Source == role.Source &&
+		slices.Equal(existing.Permissions, role.Permissions) {
+		return nil
+	}
+
+	role.CreatedAt = existing.CreatedAt
+	return st.UpdateRole(role)
+}
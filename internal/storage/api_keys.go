@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// APIKey is a long-lived credential a user creates for scripts. Only a hash
+// of the key is stored, and handlers clear even that before responding;
+// Prefix keeps the first characters so the owner can tell keys apart.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"hash,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key can still be used at now.
+func (key APIKey) Active(now time.Time) bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || now.Before(*key.ExpiresAt))
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, hash, scopes, created_at, expires_at, last_used_at, revoked_at"
+
+func (s *JSONStorage) CreateAPIKey(key APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.APIKeys[key.ID]; exists {
+		return errors.New("api key already exists")
+	}
+
+	return s.put("api_keys", key.ID, key)
+}
+
+func (s *JSONStorage) GetAPIKey(id string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, exists := s.data.APIKeys[id]
+	if !exists {
+		return APIKey{}, errors.New("api key not found")
+	}
+	return key, nil
+}
+
+func (s *JSONStorage) GetAPIKeyByHash(hash string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.data.APIKeys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return APIKey{}, errors.New("api key not found")
+}
+
+func (s *JSONStorage) ListUserAPIKeys(userID string) []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []APIKey{}
+	for _, key := range s.data.APIKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.After(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+func (s *JSONStorage) TouchAPIKey(id string, usedAt time.Time) error {
+	return s.updateAPIKey(id, func(key *APIKey) { key.LastUsedAt = &usedAt })
+}
+
+func (s *JSONStorage) RevokeAPIKey(id string) error {
+	return s.updateAPIKey(id, func(key *APIKey) {
+		if key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+		}
+	})
+}
+
+func (s *JSONStorage) updateAPIKey(id string, update func(*APIKey)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.data.APIKeys[id]
+	if !exists {
+		return errors.New("api key not found")
+	}
+
+	update(&key)
+	return s.put("api_keys", id, key)
+}
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var key APIKey
+	var scopes, createdAt, expiresAt, lastUsedAt, revokedAt string
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes,
+		&createdAt, &expiresAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return key, err
+	}
+
+	key.CreatedAt = parseTime(createdAt)
+	key.ExpiresAt = parseOptionalTime(expiresAt)
+	key.LastUsedAt = parseOptionalTime(lastUsedAt)
+	key.RevokedAt = parseOptionalTime(revokedAt)
+	return key, json.Unmarshal([]byte(scopes), &key.Scopes)
+}
+
+func (s *SQLiteStorage) CreateAPIKey(key APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO api_keys ("+apiKeyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		key.ID, key.UserID, key.Name, key.Prefix, key.Hash, string(scopes),
+		formatTime(key.CreatedAt), formatOptionalTime(key.ExpiresAt),
+		formatOptionalTime(key.LastUsedAt), formatOptionalTime(key.RevokedAt),
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetAPIKey(id string) (APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return APIKey{}, errors.New("api key not found")
+	}
+	return key, err
+}
+
+func (s *SQLiteStorage) GetAPIKeyByHash(hash string) (APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE hash = ?", hash))
+	if err == sql.ErrNoRows {
+		return APIKey{}, errors.New("api key not found")
+	}
+	return key, err
+}
+
+func (s *SQLiteStorage) ListUserAPIKeys(userID string) []APIKey {
+	keys := []APIKey{}
+
+	rows, err := s.db.Query(
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY created_at DESC, id",
+		userID,
+	)
+	if err != nil {
+		log.Println("sqlite: list api keys:", err)
+		return keys
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			log.Println("sqlite: scan api key:", err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (s *SQLiteStorage) TouchAPIKey(id string, usedAt time.Time) error {
+	result, err := s.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", formatTime(usedAt), id)
+	return requireRow(result, err, "api key not found")
+}
+
+func (s *SQLiteStorage) RevokeAPIKey(id string) error {
+	result, err := s.db.Exec(
+		"UPDATE api_keys SET revoked_at = CASE WHEN revoked_at = '' THEN ? ELSE revoked_at END WHERE id = ?",
+		formatTime(time.Now()), id,
+	)
+	return requireRow(result, err, "api key not found")
+}
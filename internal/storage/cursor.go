@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// CursorKey is a position in a collection walked in (Time, ID) order: an
+// item's or user's created_at, or an audit log's timestamp, plus its ID.
+// Unlike page offsets it stays valid while rows are added or removed.
+type CursorKey struct {
+	Time time.Time
+	ID   string
+}
+
+// CursorParams selects a window of at most Limit rows strictly after After
+// or, when Before is set, strictly before Before. With neither set the
+// window starts at the beginning of the collection.
+type CursorParams struct {
+	After  *CursorKey
+	Before *CursorKey
+	Limit  int
+	Filter map[string]string
+}
+
+// CursorPage holds one window of rows in ascending (Time, ID) order. First and
+// Last are nil when the window is empty.
+type CursorPage struct {
+	Data    interface{}
+	First   *CursorKey
+	Last    *CursorKey
+	HasPrev bool
+	HasNext bool
+}
+
+func (k CursorKey) compare(other CursorKey) int {
+	if c := k.Time.Compare(other.Time); c != 0 {
+		return c
+	}
+	return strings.Compare(k.ID, other.ID)
+}
+
+func normaliseLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPerPage
+	}
+	if limit > MaxPerPage {
+		return MaxPerPage
+	}
+	return limit
+}
+
+func itemKey(item Item) CursorKey        { return CursorKey{Time: item.CreatedAt, ID: item.ID} }
+func userKey(user User) CursorKey        { return CursorKey{Time: user.CreatedAt, ID: user.ID} }
+func auditLogKey(log AuditLog) CursorKey { return CursorKey{Time: log.Timestamp, ID: log.ID} }
+
+// cursorWindow cuts the window described by params out of rows, which must
+// already be sorted in ascending key order.
+func cursorWindow[T any](rows []T, key func(T) CursorKey, params CursorParams) CursorPage {
+	limit := normaliseLimit(params.Limit)
+
+	var start, end int
+	if params.Before != nil {
+		end = len(rows)
+		for i, row := range rows {
+			if key(row).compare(*params.Before) >= 0 {
+				end = i
+				break
+			}
+		}
+		start = end - limit
+		if start < 0 {
+			start = 0
+		}
+	} else {
+		start = len(rows)
+		for i, row := range rows {
+			if params.After == nil || key(row).compare(*params.After) > 0 {
+				start = i
+				break
+			}
+		}
+		end = start + limit
+		if end > len(rows) {
+			end = len(rows)
+		}
+	}
+
+	window := append([]T{}, rows[start:end]...)
+	page := CursorPage{Data: window}
+	if len(window) == 0 {
+		return page
+	}
+
+	first, last := key(window[0]), key(window[len(window)-1])
+	page.First, page.Last = &first, &last
+	page.HasPrev = start > 0
+	page.HasNext = end < len(rows)
+	return page
+}
+
+func (s *JSONStorage) GetItemsByCursor(params CursorParams) CursorPage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := s.getFilteredItems(params.Filter)
+	sortItems(items, "created_at", false)
+	return cursorWindow(items, itemKey, params)
+}
+
+func (s *JSONStorage) GetUsersByCursor(params CursorParams) CursorPage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := s.getFilteredUsers(params.Filter)
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	sortUsers(users, "created_at", false)
+	return cursorWindow(users, userKey, params)
+}
+
+func (s *JSONStorage) GetAuditLogsByCursor(params CursorParams) CursorPage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	logs := []AuditLog{}
+	for _, entry := range s.data.AuditLogs {
+		if matchesAuditLogFilter(entry, params.Filter) {
+			logs = append(logs, entry)
+		}
+	}
+
+	sortAuditLogs(logs)
+	for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+		logs[i], logs[j] = logs[j], logs[i]
+	}
+	return cursorWindow(logs, auditLogKey, params)
+}
+
+func matchesAuditLogFilter(entry AuditLog, filters map[string]string) bool {
+	for key, value := range filters {
+		switch key {
+		case "user_id":
+			if entry.UserID != value {
+				return false
+			}
+		case "entity":
+			if entry.Entity != value {
+				return false
+			}
+		case "action":
+			if entry.Action != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *SQLiteStorage) GetItemsByCursor(params CursorParams) CursorPage {
+	where, args := itemFilterConditions(params.Filter)
+	return sqliteCursorPage(s, "items", itemColumns, "created_at", where, args, params, s.queryItems, itemKey)
+}
+
+func (s *SQLiteStorage) GetUsersByCursor(params CursorParams) CursorPage {
+	where, args := userFilterConditions(params.Filter)
+	query := func(query string, args ...interface{}) []User {
+		users := s.queryUsers(query, args...)
+		for i := range users {
+			users[i].Password = ""
+		}
+		return users
+	}
+	return sqliteCursorPage(s, "users", userColumns, "created_at", where, args, params, query, userKey)
+}
+
+func (s *SQLiteStorage) GetAuditLogsByCursor(params CursorParams) CursorPage {
+	where, args := []string{}, []interface{}{}
+	for key, value := range params.Filter {
+		switch key {
+		case "user_id", "entity", "action":
+			where = append(where, key+" = ?")
+			args = append(args, value)
+		}
+	}
+	return sqliteCursorPage(s, "audit_logs", auditLogColumns, "timestamp", where, args, params, s.queryAuditLogs, auditLogKey)
+}
+
+// sqliteCursorPage runs a keyset query over table ordered by (timeColumn, id).
+// Windows before a cursor are read in descending order and flipped so that
+// callers always receive ascending rows.
+func sqliteCursorPage[T any](
+	s *SQLiteStorage,
+	table, columns, timeColumn string,
+	where []string, args []interface{},
+	params CursorParams,
+	query func(string, ...interface{}) []T,
+	key func(T) CursorKey,
+) CursorPage {
+	limit := normaliseLimit(params.Limit)
+	backwards := params.Before != nil
+
+	conditions := append([]string{}, where...)
+	conditionArgs := append([]interface{}{}, args...)
+	order := " ORDER BY " + timeColumn + " ASC, id ASC"
+
+	if backwards {
+		conditions = append(conditions, keyCondition(timeColumn, "<"))
+		conditionArgs = append(conditionArgs, keyArgs(*params.Before)...)
+		order = " ORDER BY " + timeColumn + " DESC, id DESC"
+	} else if params.After != nil {
+		conditions = append(conditions, keyCondition(timeColumn, ">"))
+		conditionArgs = append(conditionArgs, keyArgs(*params.After)...)
+	}
+
+	rows := query(
+		"SELECT "+columns+" FROM "+table+whereClause(conditions)+order+" LIMIT ?",
+		append(conditionArgs, limit+1)...,
+	)
+
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	if backwards {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := CursorPage{Data: rows}
+	if len(rows) == 0 {
+		return page
+	}
+
+	first, last := key(rows[0]), key(rows[len(rows)-1])
+	page.First, page.Last = &first, &last
+
+	exists := func(op string, k CursorKey) bool {
+		conditions := append(append([]string{}, where...), keyCondition(timeColumn, op))
+		conditionArgs := append(append([]interface{}{}, args...), keyArgs(k)...)
+		return s.count("SELECT EXISTS (SELECT 1 FROM "+table+whereClause(conditions)+")", conditionArgs...) == 1
+	}
+
+	if backwards {
+		page.HasPrev = more
+		page.HasNext = exists(">", last)
+	} else {
+		page.HasNext = more
+		page.HasPrev = params.After != nil && exists("<", first)
+	}
+	return page
+}
+
+func keyCondition(timeColumn, op string) string {
+	return "(" + timeColumn + " " + op + " ? OR (" + timeColumn + " = ? AND id " + op + " ?))"
+}
+
+func keyArgs(k CursorKey) []interface{} {
+	t := formatTime(k.Time)
+	return []interface{}{t, t, k.ID}
+}
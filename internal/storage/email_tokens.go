@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrEmailTokenUsed is returned when an email token is used a second time.
+var ErrEmailTokenUsed = errors.New("email token already used")
+
+// Purposes of an EmailToken.
+const (
+	EmailTokenVerify        = "verify_email"
+	EmailTokenPasswordReset = "password_reset"
+)
+
+// EmailToken is the stored half of a single-use link sent by email. ID is a
+// hash of the token in the link. Email is the address the link was sent to,
+// which a verification only confirms while it is still the user's address.
+type EmailToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	Email     string     `json:"email"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// Valid reports whether the token can still be used for purpose at now.
+func (t EmailToken) Valid(purpose string, now time.Time) bool {
+	return t.Purpose == purpose && t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
+
+const emailTokenColumns = "id, user_id, purpose, email, created_at, expires_at, used_at"
+
+func (s *JSONStorage) CreateEmailToken(token EmailToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.EmailTokens[token.ID]; exists {
+		return errors.New("email token already exists")
+	}
+
+	return s.put("email_tokens", token.ID, token)
+}
+
+func (s *JSONStorage) GetEmailToken(id string) (EmailToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, exists := s.data.EmailTokens[id]
+	if !exists {
+		return EmailToken{}, errors.New("email token not found")
+	}
+	return token, nil
+}
+
+func (s *JSONStorage) UseEmailToken(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.data.EmailTokens[id]
+	if !exists {
+		return errors.New("email token not found")
+	}
+	if token.UsedAt != nil {
+		return ErrEmailTokenUsed
+	}
+
+	now := time.Now()
+	token.UsedAt = &now
+
+	return s.put("email_tokens", id, token)
+}
+
+func (s *JSONStorage) DeleteUserEmailTokens(userID string, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := []walRecord{}
+	for id, token := range s.data.EmailTokens {
+		if token.UserID == userID && token.Purpose == purpose {
+			records = append(records, deleteRecord("email_tokens", id))
+		}
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+	return s.persist(records...)
+}
+
+func (s *JSONStorage) DeleteExpiredEmailTokens(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := []walRecord{}
+	for id, token := range s.data.EmailTokens {
+		if token.ExpiresAt.Before(before) {
+			records = append(records, deleteRecord("email_tokens", id))
+		}
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return len(records), s.persist(records...)
+}
+
+func scanEmailToken(row rowScanner) (EmailToken, error) {
+	var token EmailToken
+	var createdAt, expiresAt, usedAt string
+	err := row.Scan(&token.ID, &token.UserID, &token.Purpose, &token.Email, &createdAt, &expiresAt, &usedAt)
+	token.CreatedAt = parseTime(createdAt)
+	token.ExpiresAt = parseTime(expiresAt)
+	token.UsedAt = parseOptionalTime(usedAt)
+	return token, err
+}
+
+func (s *SQLiteStorage) CreateEmailToken(token EmailToken) error {
+	_, err := s.db.Exec(
+		"INSERT INTO email_tokens ("+emailTokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		token.ID, token.UserID, token.Purpose, token.Email,
+		formatTime(token.CreatedAt), formatTime(token.ExpiresAt), formatOptionalTime(token.UsedAt),
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetEmailToken(id string) (EmailToken, error) {
+	token, err := scanEmailToken(s.db.QueryRow("SELECT "+emailTokenColumns+" FROM email_tokens WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return EmailToken{}, errors.New("email token not found")
+	}
+	return token, err
+}
+
+func (s *SQLiteStorage) UseEmailToken(id string) error {
+	result, err := s.db.Exec(
+		"UPDATE email_tokens SET used_at = ? WHERE id = ? AND used_at = ''",
+		formatTime(time.Now()), id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	if _, err := s.GetEmailToken(id); err != nil {
+		return err
+	}
+	return ErrEmailTokenUsed
+}
+
+func (s *SQLiteStorage) DeleteUserEmailTokens(userID string, purpose string) error {
+	_, err := s.db.Exec("DELETE FROM email_tokens WHERE user_id = ? AND purpose = ?", userID, purpose)
+	return err
+}
+
+func (s *SQLiteStorage) DeleteExpiredEmailTokens(before time.Time) (int, error) {
+	result, err := s.db.Exec("DELETE FROM email_tokens WHERE expires_at < ?", formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// LoginAttempt counts the recent failed logins for one username or one
+// client IP, which the login handlers turn into a lockout. A failure after
+// LastFailedAt fell out of the counting window starts a new count.
+type LoginAttempt struct {
+	ID            string    `json:"id"`
+	Failures      int       `json:"failures"`
+	FirstFailedAt time.Time `json:"first_failed_at"`
+	LastFailedAt  time.Time `json:"last_failed_at"`
+}
+
+func UsernameAttemptID(username string) string { return "username:" + username }
+func IPAttemptID(ip string) string             { return "ip:" + ip }
+
+const loginAttemptColumns = "id, failures, first_failed_at, last_failed_at"
+
+func (s *JSONStorage) RecordLoginFailure(id string, at time.Time, resetBefore time.Time) (LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, exists := s.data.LoginAttempts[id]
+	if !exists || attempt.LastFailedAt.Before(resetBefore) {
+		attempt = LoginAttempt{ID: id, FirstFailedAt: at}
+	}
+	attempt.Failures++
+	attempt.LastFailedAt = at
+
+	return attempt, s.put("login_attempts", id, attempt)
+}
+
+func (s *JSONStorage) GetLoginAttempt(id string) (LoginAttempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attempt, exists := s.data.LoginAttempts[id]
+	if !exists {
+		return LoginAttempt{}, errors.New("login attempt not found")
+	}
+	return attempt, nil
+}
+
+func (s *JSONStorage) ListLoginAttempts() []LoginAttempt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attempts := make([]LoginAttempt, 0, len(s.data.LoginAttempts))
+	for _, attempt := range s.data.LoginAttempts {
+		attempts = append(attempts, attempt)
+	}
+	sort.Slice(attempts, func(i, j int) bool {
+		return attempts[i].LastFailedAt.After(attempts[j].LastFailedAt)
+	})
+	return attempts
+}
+
+func (s *JSONStorage) DeleteLoginAttempt(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.LoginAttempts[id]; !exists {
+		return errors.New("login attempt not found")
+	}
+
+	return s.remove("login_attempts", id)
+}
+
+func (s *JSONStorage) DeleteExpiredLoginAttempts(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := []walRecord{}
+	for id, attempt := range s.data.LoginAttempts {
+		if attempt.LastFailedAt.Before(before) {
+			records = append(records, deleteRecord("login_attempts", id))
+		}
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return len(records), s.persist(records...)
+}
+
+func scanLoginAttempt(row rowScanner) (LoginAttempt, error) {
+	var attempt LoginAttempt
+	var firstFailedAt, lastFailedAt string
+	err := row.Scan(&attempt.ID, &attempt.Failures, &firstFailedAt, &lastFailedAt)
+	attempt.FirstFailedAt = parseTime(firstFailedAt)
+	attempt.LastFailedAt = parseTime(lastFailedAt)
+	return attempt, err
+}
+
+func (s *SQLiteStorage) RecordLoginFailure(id string, at time.Time, resetBefore time.Time) (LoginAttempt, error) {
+	_, err := s.db.Exec(
+		"INSERT INTO login_attempts ("+loginAttemptColumns+") VALUES (?, 1, ?, ?) "+
+			"ON CONFLICT (id) DO UPDATE SET "+
+			"failures = CASE WHEN last_failed_at < ? THEN 1 ELSE failures + 1 END, "+
+			"first_failed_at = CASE WHEN last_failed_at < ? THEN excluded.first_failed_at ELSE first_failed_at END, "+
+			"last_failed_at = excluded.last_failed_at",
+		id, formatTime(at), formatTime(at), formatTime(resetBefore), formatTime(resetBefore),
+	)
+	if err != nil {
+		return LoginAttempt{}, err
+	}
+	return s.GetLoginAttempt(id)
+}
+
+func (s *SQLiteStorage) GetLoginAttempt(id string) (LoginAttempt, error) {
+	attempt, err := scanLoginAttempt(s.db.QueryRow("SELECT "+loginAttemptColumns+" FROM login_attempts WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return LoginAttempt{}, errors.New("login attempt not found")
+	}
+	return attempt, err
+}
+
+func (s *SQLiteStorage) ListLoginAttempts() []LoginAttempt {
+	attempts := []LoginAttempt{}
+
+	rows, err := s.db.Query("SELECT " + loginAttemptColumns + " FROM login_attempts ORDER BY last_failed_at DESC")
+	if err != nil {
+		log.Println("sqlite: list login attempts:", err)
+		return attempts
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attempt, err := scanLoginAttempt(rows)
+		if err != nil {
+			log.Println("sqlite: scan login attempt:", err)
+			continue
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts
+}
+
+func (s *SQLiteStorage) DeleteLoginAttempt(id string) error {
+	result, err := s.db.Exec("DELETE FROM login_attempts WHERE id = ?", id)
+	return requireRow(result, err, "login attempt not found")
+}
+
+func (s *SQLiteStorage) DeleteExpiredLoginAttempts(before time.Time) (int, error) {
+	result, err := s.db.Exec("DELETE FROM login_attempts WHERE last_failed_at < ?", formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// sqliteMigrations is the ordered schema history of the SQLite backend. New
+// schema changes are appended with the next version number; applied entries
+// must never be edited.
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		name:    "initial schema",
+		sql: `
+CREATE TABLE users (
+	id         TEXT PRIMARY KEY,
+	username   TEXT NOT NULL UNIQUE,
+	password   TEXT NOT NULL,
+	email      TEXT NOT NULL,
+	role       TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+
+CREATE TABLE categories (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	description TEXT NOT NULL,
+	created_at  TEXT NOT NULL,
+	created_by  TEXT NOT NULL
+);
+
+CREATE TABLE tags (
+	id         TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	created_by TEXT NOT NULL
+);
+
+CREATE TABLE items (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	description TEXT NOT NULL,
+	price       REAL NOT NULL,
+	category_id TEXT NOT NULL,
+	image_url   TEXT NOT NULL,
+	created_at  TEXT NOT NULL,
+	updated_at  TEXT NOT NULL,
+	created_by  TEXT NOT NULL
+);
+
+CREATE INDEX idx_items_category_id ON items (category_id);
+
+CREATE TABLE item_tags (
+	item_id  TEXT NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+	tag_id   TEXT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (item_id, tag_id)
+);
+
+CREATE INDEX idx_item_tags_tag_id ON item_tags (tag_id);
+
+CREATE TABLE audit_logs (
+	id        TEXT PRIMARY KEY,
+	action    TEXT NOT NULL,
+	entity    TEXT NOT NULL,
+	entity_id TEXT NOT NULL,
+	user_id   TEXT NOT NULL,
+	timestamp TEXT NOT NULL,
+	details   TEXT NOT NULL
+);
+
+CREATE INDEX idx_audit_logs_timestamp ON audit_logs (timestamp);
+
+CREATE TABLE analytics (
+	id   INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+`,
+	},
+	{
+		version: 2,
+		name:    "unique category names",
+		sql:     `CREATE UNIQUE INDEX idx_categories_name ON categories (name COLLATE NOCASE);`,
+	},
+	{
+		version: 3,
+		name:    "unique tag names",
+		sql:     `CREATE UNIQUE INDEX idx_tags_name ON tags (name COLLATE NOCASE);`,
+	},
+	{
+		version: 4,
+		name:    "refresh tokens",
+		sql: `
+CREATE TABLE refresh_tokens (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	family_id  TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL,
+	used_at    TEXT NOT NULL DEFAULT '',
+	revoked_at TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens (expires_at);
+`,
+	},
+	{
+		version: 5,
+		name:    "token revocations",
+		sql: `
+CREATE TABLE revocations (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	token_id   TEXT NOT NULL,
+	revoked_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL
+);
+
+CREATE INDEX idx_revocations_expires_at ON revocations (expires_at);
+`,
+	},
+	{
+		version: 6,
+		name:    "sessions",
+		sql: `
+CREATE TABLE sessions (
+	id           TEXT PRIMARY KEY,
+	user_id      TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	user_agent   TEXT NOT NULL,
+	ip           TEXT NOT NULL,
+	created_at   TEXT NOT NULL,
+	last_seen_at TEXT NOT NULL,
+	expires_at   TEXT NOT NULL,
+	revoked_at   TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX idx_sessions_expires_at ON sessions (expires_at);
+`,
+	},
+	{
+		version: 7,
+		name:    "roles",
+		sql: `
+CREATE TABLE roles (
+	name        TEXT PRIMARY KEY,
+	description TEXT NOT NULL,
+	permissions TEXT NOT NULL,
+	source      TEXT NOT NULL,
+	created_at  TEXT NOT NULL,
+	updated_at  TEXT NOT NULL
+);
+`,
+	},
+	{
+		version: 8,
+		name:    "disabled users",
+		sql:     `ALTER TABLE users ADD COLUMN disabled_at TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		version: 9,
+		name:    "forced password changes",
+		sql:     `ALTER TABLE users ADD COLUMN must_change_password INTEGER NOT NULL DEFAULT 0;`,
+	},
+	{
+		version: 10,
+		name:    "api keys",
+		sql: `
+CREATE TABLE api_keys (
+	id           TEXT PRIMARY KEY,
+	user_id      TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name         TEXT NOT NULL,
+	prefix       TEXT NOT NULL,
+	hash         TEXT NOT NULL UNIQUE,
+	scopes       TEXT NOT NULL,
+	created_at   TEXT NOT NULL,
+	expires_at   TEXT NOT NULL DEFAULT '',
+	last_used_at TEXT NOT NULL DEFAULT '',
+	revoked_at   TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
+`,
+	},
+	{
+		version: 11,
+		name:    "two-factor authentication",
+		sql: `
+CREATE TABLE two_factor (
+	user_id        TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	secret         TEXT NOT NULL,
+	recovery_codes TEXT NOT NULL,
+	last_step      INTEGER NOT NULL,
+	created_at     TEXT NOT NULL,
+	enabled_at     TEXT NOT NULL DEFAULT ''
+);
+`,
+	},
+	{
+		version: 12,
+		name:    "login attempts",
+		sql: `
+CREATE TABLE login_attempts (
+	id              TEXT PRIMARY KEY,
+	failures        INTEGER NOT NULL,
+	first_failed_at TEXT NOT NULL,
+	last_failed_at  TEXT NOT NULL
+);
+`,
+	},
+	{
+		version: 13,
+		name:    "email verification and password reset",
+		sql: `
+ALTER TABLE users ADD COLUMN must_verify_email INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE email_tokens (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	purpose    TEXT NOT NULL,
+	email      TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL,
+	used_at    TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_email_tokens_user_id ON email_tokens (user_id);
+`,
+	},
+}
+
+// migrate brings db up to the latest version in migrations. Each migration
+// runs in its own transaction together with its schema_migrations record, so
+// a failure leaves the database at the last fully applied version.
+func migrate(db *sql.DB, migrations []migration) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(m.sql); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			m.version, m.name, formatTime(time.Now()),
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSchemaVersion verifies that db has every migration applied without
+// modifying it, for databases opened read-only.
+func checkSchemaVersion(db *sql.DB, migrations []migration) error {
+	var current int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	latest := migrations[len(migrations)-1].version
+	if current < latest {
+		return fmt.Errorf("database schema is at version %d, expected %d; open it read-write once to migrate", current, latest)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openRawSQLite(t *testing.T, path string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func schemaVersions(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+
+	rows, err := db.Query("SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	versions := []int{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+func TestSQLiteMigrationsAreOrdered(t *testing.T) {
+	for i, m := range sqliteMigrations {
+		if m.version != i+1 {
+			t.Errorf("migration %d (%s) has version %d, want %d", i, m.name, m.version, i+1)
+		}
+		if strings.TrimSpace(m.name) == "" || strings.TrimSpace(m.sql) == "" {
+			t.Errorf("migration %d is missing a name or sql", m.version)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	latest := len(sqliteMigrations)
+	broken := append(append([]migration{}, sqliteMigrations[:3]...), migration{version: 4, name: "broken", sql: "CREATE TABLE users (id TEXT);"})
+
+	tests := []struct {
+		name string
+		// steps are applied one after another to the same database.
+		steps        [][]migration
+		wantErr      bool
+		wantVersions int
+	}{
+		{"fresh database", [][]migration{sqliteMigrations}, false, latest},
+		{"already migrated", [][]migration{sqliteMigrations, sqliteMigrations}, false, latest},
+		{"from an older version", [][]migration{sqliteMigrations[:7], sqliteMigrations}, false, latest},
+		{"failing migration keeps earlier ones", [][]migration{broken}, true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openRawSQLite(t, filepath.Join(t.TempDir(), "storage.db"))
+
+			var err error
+			for _, step := range tt.steps {
+				if err = migrate(db, step); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("migrate error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			versions := schemaVersions(t, db)
+			if len(versions) != tt.wantVersions {
+				t.Fatalf("applied versions = %v, want 1 through %d", versions, tt.wantVersions)
+			}
+			for i, version := range versions {
+				if version != i+1 {
+					t.Errorf("applied versions = %v, want 1 through %d", versions, tt.wantVersions)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateKeepsExistingRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+	db := openRawSQLite(t, path)
+
+	// Version 7 predates disabled users, forced password changes and email
+	// verification, which later migrations add as columns with defaults.
+	if err := migrate(db, sqliteMigrations[:7]); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	_, err := db.Exec(`INSERT INTO users (id, username, password, email, role, created_at, updated_at)
+		VALUES ('alice', 'alice', 'hash', 'alice@example.com', 'user', ?, ?)`,
+		"2026-01-02T03:04:05.000000000Z", "2026-01-02T03:04:05.000000000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewSQLiteStorage(path, false)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer s.Close()
+
+	user, err := s.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if user.Username != "alice" || user.Email != "alice@example.com" || user.Disabled() || user.MustChangePassword || user.MustVerifyEmail {
+		t.Errorf("GetUser = %+v, want alice with the new columns at their defaults", user)
+	}
+}
+
+func TestSQLiteReadOnlyRequiresCurrentSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []migration
+		wantErr    bool
+	}{
+		{"current", sqliteMigrations, false},
+		{"outdated", sqliteMigrations[:len(sqliteMigrations)-1], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage.db")
+			db := openRawSQLite(t, path)
+			if err := migrate(db, tt.migrations); err != nil {
+				t.Fatalf("migrate: %v", err)
+			}
+			db.Close()
+
+			s, err := NewStorage(Options{Backend: BackendSQLite, Path: path, ReadOnly: true})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewStorage error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer s.Close()
+
+			if err := s.CreateUser(testUser("alice")); err != ErrReadOnly {
+				t.Errorf("CreateUser error = %v, want %v", err, ErrReadOnly)
+			}
+
+			// A read-only open must not have migrated anything.
+			if versions := schemaVersions(t, openRawSQLite(t, path)); len(versions) != len(tt.migrations) {
+				t.Errorf("applied versions = %v, want %d", versions, len(tt.migrations))
+			}
+		})
+	}
+}
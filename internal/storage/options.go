@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
+var ErrReadOnly = errors.New("storage is read-only")
+
+// Options selects and configures a storage backend for NewStorage.
+type Options struct {
+	// Path is the JSON snapshot file or SQLite database file. When empty the
+	// backend's default path under ./pkg/storage is used.
+	Path string
+	// Backend is BackendJSON (the default) or BackendSQLite.
+	Backend string
+	// ReadOnly opens the store without modifying it on disk; every mutation
+	// returns ErrReadOnly.
+	ReadOnly bool
+}
+
+func DefaultPath(backend string) string {
+	if backend == BackendSQLite {
+		return "./pkg/storage/storage.db"
+	}
+	return "./pkg/storage/storage.json"
+}
+
+func NewStorage(opts Options) (Store, error) {
+	if opts.Backend == "" {
+		opts.Backend = BackendJSON
+	}
+	if opts.Path == "" {
+		opts.Path = DefaultPath(opts.Backend)
+	}
+
+	var st Store
+	var err error
+	switch opts.Backend {
+	case BackendJSON:
+		st, err = NewJSONStorage(opts.Path, opts.ReadOnly)
+	case BackendSQLite:
+		st, err = NewSQLiteStorage(opts.Path, opts.ReadOnly)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", opts.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ReadOnly {
+		return readOnlyStore{st}, nil
+	}
+	return st, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import "time"
+
+// readOnlyStore rejects every mutation with ErrReadOnly before it reaches the
+// underlying backend, so read-only instances never diverge from disk.
+type readOnlyStore struct {
+	Store
+}
+
+func (readOnlyStore) CreateUser(User) error               { return ErrReadOnly }
+func (readOnlyStore) UpdateUser(User) error               { return ErrReadOnly }
+func (readOnlyStore) DeleteUser(string, string) error     { return ErrReadOnly }
+func (readOnlyStore) UpdateUserRole(string, string) error { return ErrReadOnly }
+func (readOnlyStore) CreateItem(Item) error               { return ErrReadOnly }
+func (readOnlyStore) UpdateItem(Item) error               { return ErrReadOnly }
+func (readOnlyStore) DeleteItem(string) error             { return ErrReadOnly }
+func (readOnlyStore) CreateCategory(Category) error       { return ErrReadOnly }
+func (readOnlyStore) UpdateCategory(Category) error       { return ErrReadOnly }
+func (readOnlyStore) DeleteCategory(string, string) error { return ErrReadOnly }
+func (readOnlyStore) CreateTag(Tag) error                 { return ErrReadOnly }
+func (readOnlyStore) UpdateTag(Tag) error                 { return ErrReadOnly }
+func (readOnlyStore) DeleteTag(string) error              { return ErrReadOnly }
+func (readOnlyStore) AddItemTag(string, string) error     { return ErrReadOnly }
+func (readOnlyStore) RemoveItemTag(string, string) error  { return ErrReadOnly }
+func (readOnlyStore) CreateAuditLog(AuditLog) error       { return ErrReadOnly }
+func (readOnlyStore) UpdateAnalytics() error              { return ErrReadOnly }
+
+func (readOnlyStore) CreateRefreshToken(RefreshToken) error             { return ErrReadOnly }
+func (readOnlyStore) RotateRefreshToken(string, RefreshToken) error     { return ErrReadOnly }
+func (readOnlyStore) RevokeRefreshTokenFamily(string) error             { return ErrReadOnly }
+func (readOnlyStore) DeleteExpiredRefreshTokens(time.Time) (int, error) { return 0, ErrReadOnly }
+func (readOnlyStore) RevokeUserRefreshTokens(string) error              { return ErrReadOnly }
+func (readOnlyStore) PutRevocation(Revocation) error                    { return ErrReadOnly }
+func (readOnlyStore) DeleteExpiredRevocations(time.Time) (int, error)   { return 0, ErrReadOnly }
+func (readOnlyStore) CreateSession(Session) error                       { return ErrReadOnly }
+func (readOnlyStore) TouchSession(string, time.Time) error              { return nil }
+func (readOnlyStore) ExtendSession(string, time.Time) error             { return ErrReadOnly }
+func (readOnlyStore) RevokeSession(string) error                        { return ErrReadOnly }
+func (readOnlyStore) RevokeUserSessions(string) error                   { return ErrReadOnly }
+func (readOnlyStore) DeleteExpiredSessions(time.Time) (int, error)      { return 0, ErrReadOnly }
+func (readOnlyStore) CreateRole(Role) error                             { return ErrReadOnly }
+func (readOnlyStore) UpdateRole(Role) error                             { return ErrReadOnly }
+func (readOnlyStore) DeleteRole(string) error                           { return ErrReadOnly }
+func (readOnlyStore) CreateAPIKey(APIKey) error                         { return ErrReadOnly }
+func (readOnlyStore) TouchAPIKey(string, time.Time) error               { return nil }
+func (readOnlyStore) RevokeAPIKey(string) error                         { return ErrReadOnly }
+func (readOnlyStore) PutTwoFactor(TwoFactor) error                      { return ErrReadOnly }
+func (readOnlyStore) DeleteTwoFactor(string) error                      { return ErrReadOnly }
+func (readOnlyStore) UseTwoFactorStep(string, int64) error              { return ErrReadOnly }
+func (readOnlyStore) UseRecoveryCode(string, string) error              { return ErrReadOnly }
+func (readOnlyStore) DeleteLoginAttempt(string) error                   { return ErrReadOnly }
+func (readOnlyStore) DeleteExpiredLoginAttempts(time.Time) (int, error) { return 0, ErrReadOnly }
+func (readOnlyStore) CreateEmailToken(EmailToken) error                 { return ErrReadOnly }
+func (readOnlyStore) UseEmailToken(string) error                        { return ErrReadOnly }
+func (readOnlyStore) DeleteUserEmailTokens(string, string) error        { return ErrReadOnly }
+func (readOnlyStore) DeleteExpiredEmailTokens(time.Time) (int, error)   { return 0, ErrReadOnly }
+
+func (readOnlyStore) RecordLoginFailure(string, time.Time, time.Time) (LoginAttempt, error) {
+	return LoginAttempt{}, ErrReadOnly
+}
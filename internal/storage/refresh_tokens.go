@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrTokenReused is returned when a refresh token that has already been
+// rotated or revoked is presented again.
+var ErrTokenReused = errors.New("refresh token already used")
+
+// RefreshToken is the stored half of a refresh token. ID is a hash of the
+// token handed to the client; the token itself is never stored. Every token
+// obtained by rotating another shares its FamilyID, so a whole login can be
+// revoked at once.
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+const refreshTokenColumns = "id, user_id, family_id, created_at, expires_at, used_at, revoked_at"
+
+func (s *JSONStorage) CreateRefreshToken(token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.RefreshTokens[token.ID]; exists {
+		return errors.New("refresh token already exists")
+	}
+
+	return s.put("refresh_tokens", token.ID, token)
+}
+
+func (s *JSONStorage) GetRefreshToken(id string) (RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, exists := s.data.RefreshTokens[id]
+	if !exists {
+		return RefreshToken{}, errors.New("refresh token not found")
+	}
+	return token, nil
+}
+
+func (s *JSONStorage) RotateRefreshToken(id string, next RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.data.RefreshTokens[id]
+	if !exists {
+		return errors.New("refresh token not found")
+	}
+	if token.UsedAt != nil || token.RevokedAt != nil {
+		return ErrTokenReused
+	}
+
+	now := time.Now()
+	token.UsedAt = &now
+
+	used, err := putRecord("refresh_tokens", token.ID, token)
+	if err != nil {
+		return err
+	}
+	created, err := putRecord("refresh_tokens", next.ID, next)
+	if err != nil {
+		return err
+	}
+
+	return s.persist(used, created)
+}
+
+func (s *JSONStorage) RevokeRefreshTokenFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	records := []walRecord{}
+	for id, token := range s.data.RefreshTokens {
+		if token.FamilyID != familyID || token.RevokedAt != nil {
+			continue
+		}
+
+		token.RevokedAt = &now
+		record, err := putRecord("refresh_tokens", id, token)
+		if err != nil {
+			return err
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+	return s.persist(records...)
+}
+
+func (s *JSONStorage) DeleteExpiredRefreshTokens(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := []walRecord{}
+	for id, token := range s.data.RefreshTokens {
+		if token.ExpiresAt.Before(before) {
+			records = append(records, deleteRecord("refresh_tokens", id))
+		}
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return len(records), s.persist(records...)
+}
+
+func scanRefreshToken(row rowScanner) (RefreshToken, error) {
+	var token RefreshToken
+	var createdAt, expiresAt, usedAt, revokedAt string
+	err := row.Scan(&token.ID, &token.UserID, &token.FamilyID, &createdAt, &expiresAt, &usedAt, &revokedAt)
+	token.CreatedAt = parseTime(createdAt)
+	token.ExpiresAt = parseTime(expiresAt)
+	token.UsedAt = parseOptionalTime(usedAt)
+	token.RevokedAt = parseOptionalTime(revokedAt)
+	return token, err
+}
+
+func (s *SQLiteStorage) CreateRefreshToken(token RefreshToken) error {
+	return insertRefreshToken(s.db, token)
+}
+
+func insertRefreshToken(db execer, token RefreshToken) error {
+	_, err := db.Exec(
+		"INSERT INTO refresh_tokens ("+refreshTokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		token.ID, token.UserID, token.FamilyID, formatTime(token.CreatedAt), formatTime(token.ExpiresAt),
+		formatOptionalTime(token.UsedAt), formatOptionalTime(token.RevokedAt),
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetRefreshToken(id string) (RefreshToken, error) {
+	token, err := scanRefreshToken(s.db.QueryRow("SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return RefreshToken{}, errors.New("refresh token not found")
+	}
+	return token, err
+}
+
+func (s *SQLiteStorage) RotateRefreshToken(id string, next RefreshToken) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at = '' AND revoked_at = ''",
+		formatTime(time.Now()), id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM refresh_tokens WHERE id = ?", id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return errors.New("refresh token not found")
+		}
+		return ErrTokenReused
+	}
+
+	if err := insertRefreshToken(tx, next); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) RevokeRefreshTokenFamily(familyID string) error {
+	_, err := s.db.Exec(
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at = ''",
+		formatTime(time.Now()), familyID,
+	)
+	return err
+}
+
+func (s *SQLiteStorage) DeleteExpiredRefreshTokens(before time.Time) (int, error) {
+	result, err := s.db.Exec("DELETE FROM refresh_tokens WHERE expires_at < ?", formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
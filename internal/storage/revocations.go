@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Revocation invalidates access tokens before they expire. A token
+// revocation names a single token by its ID; a user revocation rejects every
+// token issued to UserID up to RevokedAt. Entries are only needed until the
+// tokens they cover would have expired anyway, which is ExpiresAt.
+type Revocation struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	TokenID   string    `json:"token_id,omitempty"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func TokenRevocationID(tokenID string) string { return "token:" + tokenID }
+func UserRevocationID(userID string) string   { return "user:" + userID }
+
+const revocationColumns = "id, user_id, token_id, revoked_at, expires_at"
+
+func (s *JSONStorage) PutRevocation(revocation Revocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.put("revocations", revocation.ID, revocation)
+}
+
+func (s *JSONStorage) GetRevocation(id string) (Revocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revocation, exists := s.data.Revocations[id]
+	if !exists {
+		return Revocation{}, errors.New("revocation not found")
+	}
+	return revocation, nil
+}
+
+func (s *JSONStorage) DeleteExpiredRevocations(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := []walRecord{}
+	for id, revocation := range s.data.Revocations {
+		if revocation.ExpiresAt.Before(before) {
+			records = append(records, deleteRecord("revocations", id))
+		}
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return len(records), s.persist(records...)
+}
+
+func (s *JSONStorage) RevokeUserRefreshTokens(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	records := []walRecord{}
+	for id, token := range s.data.RefreshTokens {
+		if token.UserID != userID || token.RevokedAt != nil {
+			continue
+		}
+
+		token.RevokedAt = &now
+		record, err := putRecord("refresh_tokens", id, token)
+		if err != nil {
+			return err
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+	return s.persist(records...)
+}
+
+func scanRevocation(row rowScanner) (Revocation, error) {
+	var revocation Revocation
+	var revokedAt, expiresAt string
+	err := row.Scan(&revocation.ID, &revocation.UserID, &revocation.TokenID, &revokedAt, &expiresAt)
+	revocation.RevokedAt = parseTime(revokedAt)
+	revocation.ExpiresAt = parseTime(expiresAt)
+	return revocation, err
+}
+
+func (s *SQLiteStorage) PutRevocation(revocation Revocation) error {
+	_, err := s.db.Exec(
+		"INSERT INTO revocations ("+revocationColumns+") VALUES (?, ?, ?, ?, ?) "+
+			"ON CONFLICT (id) DO UPDATE SET user_id = excluded.user_id, token_id = excluded.token_id, "+
+			"revoked_at = excluded.revoked_at, expires_at = excluded.expires_at",
+		revocation.ID, revocation.UserID, revocation.TokenID,
+		formatTime(revocation.RevokedAt), formatTime(revocation.ExpiresAt),
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetRevocation(id string) (Revocation, error) {
+	revocation, err := scanRevocation(s.db.QueryRow("SELECT "+revocationColumns+" FROM revocations WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return Revocation{}, errors.New("revocation not found")
+	}
+	return revocation, err
+}
+
+func (s *SQLiteStorage) DeleteExpiredRevocations(before time.Time) (int, error) {
+	result, err := s.db.Exec("DELETE FROM revocations WHERE expires_at < ?", formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLiteStorage) RevokeUserRefreshTokens(userID string) error {
+	_, err := s.db.Exec(
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at = ''",
+		formatTime(time.Now()), userID,
+	)
+	return err
+}
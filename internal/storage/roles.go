@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// Role sources record where a role is defined. Only roles created through the
+// API may be changed through it; the others are owned by the code or by the
+// roles section of the configuration file.
+const (
+	RoleSourceBuiltin = "builtin"
+	RoleSourceConfig  = "config"
+	RoleSourceAPI     = "api"
+)
+
+// ErrRoleInUse is returned when deleting a role that users are assigned to.
+var ErrRoleInUse = errors.New("role is assigned to users")
+
+// Role is a named set of permissions that users are assigned by name.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	Source      string    `json:"source"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const roleColumns = "name, description, permissions, source, created_at, updated_at"
+
+func (s *JSONStorage) CreateRole(role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.Roles[role.Name]; exists {
+		return errors.New("role already exists")
+	}
+
+	return s.put("roles", role.Name, role)
+}
+
+func (s *JSONStorage) GetRole(name string) (Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	role, exists := s.data.Roles[name]
+	if !exists {
+		return Role{}, errors.New("role not found")
+	}
+	return role, nil
+}
+
+func (s *JSONStorage) UpdateRole(role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.Roles[role.Name]; !exists {
+		return errors.New("role not found")
+	}
+
+	role.UpdatedAt = time.Now()
+	return s.put("roles", role.Name, role)
+}
+
+func (s *JSONStorage) DeleteRole(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.Roles[name]; !exists {
+		return errors.New("role not found")
+	}
+	for _, user := range s.data.Users {
+		if user.Role == name {
+			return ErrRoleInUse
+		}
+	}
+
+	return s.remove("roles", name)
+}
+
+func (s *JSONStorage) ListRoles() []Role {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := make([]Role, 0, len(s.data.Roles))
+	for _, role := range s.data.Roles {
+		roles = append(roles, role)
+	}
+
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+func scanRole(row rowScanner) (Role, error) {
+	var role Role
+	var permissions, createdAt, updatedAt string
+	err := row.Scan(&role.Name, &role.Description, &permissions, &role.Source, &createdAt, &updatedAt)
+	if err != nil {
+		return role, err
+	}
+
+	role.CreatedAt = parseTime(createdAt)
+	role.UpdatedAt = parseTime(updatedAt)
+	return role, json.Unmarshal([]byte(permissions), &role.Permissions)
+}
+
+func (s *SQLiteStorage) CreateRole(role Role) error {
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO roles ("+roleColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		role.Name, role.Description, string(permissions), role.Source,
+		formatTime(role.CreatedAt), formatTime(role.UpdatedAt),
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetRole(name string) (Role, error) {
+	role, err := scanRole(s.db.QueryRow("SELECT "+roleColumns+" FROM roles WHERE name = ?", name))
+	if err == sql.ErrNoRows {
+		return Role{}, errors.New("role not found")
+	}
+	return role, err
+}
+
+func (s *SQLiteStorage) UpdateRole(role Role) error {
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(
+		"UPDATE roles SET description = ?, permissions = ?, source = ?, updated_at = ? WHERE name = ?",
+		role.Description, string(permissions), role.Source, formatTime(time.Now()), role.Name,
+	)
+	return requireRow(result, err, "role not found")
+}
+
+func (s *SQLiteStorage) DeleteRole(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var assigned int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", name).Scan(&assigned); err != nil {
+		return err
+	}
+	if assigned > 0 {
+		return ErrRoleInUse
+	}
+
+	result, err := tx.Exec("DELETE FROM roles WHERE name = ?", name)
+	if err := requireRow(result, err, "role not found"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) ListRoles() []Role {
+	roles := []Role{}
+
+	rows, err := s.db.Query("SELECT " + roleColumns + " FROM roles ORDER BY name")
+	if err != nil {
+		log.Println("sqlite: list roles:", err)
+		return roles
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			log.Println("sqlite: scan role:", err)
+			continue
+		}
+		roles = append(roles, role)
+	}
+	return roles
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// Session records one login. Its ID doubles as the FamilyID of the refresh
+// tokens issued for it and is carried in the sid claim of its access tokens,
+// so revoking the session cuts off both.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the session can still be used at now.
+func (session Session) Active(now time.Time) bool {
+	return session.RevokedAt == nil && now.Before(session.ExpiresAt)
+}
+
+const sessionColumns = "id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at"
+
+func (s *JSONStorage) CreateSession(session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.Sessions[session.ID]; exists {
+		return errors.New("session already exists")
+	}
+
+	return s.put("sessions", session.ID, session)
+}
+
+func (s *JSONStorage) GetSession(id string) (Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.data.Sessions[id]
+	if !exists {
+		return Session{}, errors.New("session not found")
+	}
+	return session, nil
+}
+
+func (s *JSONStorage) ListUserSessions(userID string) []Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := []Session{}
+	for _, session := range s.data.Sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions
+}
+
+func (s *JSONStorage) TouchSession(id string, seenAt time.Time) error {
+	return s.updateSession(id, func(session *Session) { session.LastSeenAt = seenAt })
+}
+
+func (s *JSONStorage) ExtendSession(id string, expiresAt time.Time) error {
+	return s.updateSession(id, func(session *Session) { session.ExpiresAt = expiresAt })
+}
+
+func (s *JSONStorage) RevokeSession(id string) error {
+	now := time.Now()
+	return s.updateSession(id, func(session *Session) { session.RevokedAt = &now })
+}
+
+// updateSession applies change to an unrevoked session. Revoked sessions are
+// left untouched so that a late update can never reopen one.
+func (s *JSONStorage) updateSession(id string, change func(*Session)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.data.Sessions[id]
+	if !exists {
+		return errors.New("session not found")
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+
+	change(&session)
+	return s.put("sessions", id, session)
+}
+
+func (s *JSONStorage) RevokeUserSessions(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	records := []walRecord{}
+	for id, session := range s.data.Sessions {
+		if session.UserID != userID || session.RevokedAt != nil {
+			continue
+		}
+
+		session.RevokedAt = &now
+		record, err := putRecord("sessions", id, session)
+		if err != nil {
+			return err
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+	return s.persist(records...)
+}
+
+func (s *JSONStorage) DeleteExpiredSessions(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := []walRecord{}
+	for id, session := range s.data.Sessions {
+		if session.ExpiresAt.Before(before) {
+			records = append(records, deleteRecord("sessions", id))
+		}
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return len(records), s.persist(records...)
+}
+
+func scanSession(row rowScanner) (Session, error) {
+	var session Session
+	var createdAt, lastSeenAt, expiresAt, revokedAt string
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &createdAt, &lastSeenAt, &expiresAt, &revokedAt)
+	session.CreatedAt = parseTime(createdAt)
+	session.LastSeenAt = parseTime(lastSeenAt)
+	session.ExpiresAt = parseTime(expiresAt)
+	session.RevokedAt = parseOptionalTime(revokedAt)
+	return session, err
+}
+
+func (s *SQLiteStorage) CreateSession(session Session) error {
+	_, err := s.db.Exec(
+		"INSERT INTO sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		session.ID, session.UserID, session.UserAgent, session.IP,
+		formatTime(session.CreatedAt), formatTime(session.LastSeenAt), formatTime(session.ExpiresAt),
+		formatOptionalTime(session.RevokedAt),
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetSession(id string) (Session, error) {
+	session, err := scanSession(s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return Session{}, errors.New("session not found")
+	}
+	return session, err
+}
+
+func (s *SQLiteStorage) ListUserSessions(userID string) []Session {
+	sessions := []Session{}
+
+	rows, err := s.db.Query(
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? ORDER BY last_seen_at DESC, id",
+		userID,
+	)
+	if err != nil {
+		log.Println("sqlite: list sessions:", err)
+		return sessions
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			log.Println("sqlite: scan session:", err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+func (s *SQLiteStorage) TouchSession(id string, seenAt time.Time) error {
+	result, err := s.db.Exec(
+		"UPDATE sessions SET last_seen_at = ? WHERE id = ? AND revoked_at = ''",
+		formatTime(seenAt), id,
+	)
+	return requireSession(s, id, result, err)
+}
+
+func (s *SQLiteStorage) ExtendSession(id string, expiresAt time.Time) error {
+	result, err := s.db.Exec(
+		"UPDATE sessions SET expires_at = ? WHERE id = ? AND revoked_at = ''",
+		formatTime(expiresAt), id,
+	)
+	return requireSession(s, id, result, err)
+}
+
+func (s *SQLiteStorage) RevokeSession(id string) error {
+	result, err := s.db.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at = ''",
+		formatTime(time.Now()), id,
+	)
+	return requireSession(s, id, result, err)
+}
+
+// requireSession turns an update that matched no unrevoked session into a
+// not-found error when the session does not exist at all. Updates to revoked
+// sessions are silently ignored, as in the JSON backend.
+func requireSession(s *SQLiteStorage, id string, result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	if s.count("SELECT COUNT(*) FROM sessions WHERE id = ?", id) == 0 {
+		return errors.New("session not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) RevokeUserSessions(userID string) error {
+	_, err := s.db.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at = ''",
+		formatTime(time.Now()), userID,
+	)
+	return err
+}
+
+func (s *SQLiteStorage) DeleteExpiredSessions(before time.Time) (int, error) {
+	result, err := s.db.Exec("DELETE FROM sessions WHERE expires_at < ?", formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// DefaultSnapshotBackups is how many previous snapshots the JSON backend keeps
+// next to the primary file as <path>.1 (newest) through <path>.N (oldest).
+const DefaultSnapshotBackups = 3
+
+// writeSnapshot replaces path with data without ever exposing a partially
+// written file: data goes to a temporary file in the same directory, is
+// fsynced and then renamed over path after the previous version has been
+// rotated into the backups.
+func writeSnapshot(path string, data []byte, backups int) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := rotateBackups(path, backups); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// rotateBackups shifts <path>.1..<path>.N-1 up by one, dropping the oldest,
+// and moves the current primary file to <path>.1.
+func rotateBackups(path string, backups int) error {
+	if backups <= 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	os.Remove(backupPath(path, backups))
+	for i := backups - 1; i >= 1; i-- {
+		if err := os.Rename(backupPath(path, i), backupPath(path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(path, backupPath(path, 1))
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Some platforms do not support fsync on directories; the rename itself
+	// has already succeeded at this point.
+	d.Sync()
+	return nil
+}
+
+// readSnapshot decodes the primary snapshot at path into data. If the primary
+// is missing or corrupt it falls back to the newest backup that decodes
+// cleanly. A store with neither a primary nor any backups is treated as new.
+// With readOnly set nothing on disk is touched.
+func readSnapshot(path string, backups int, readOnly bool, data *StorageData) error {
+	if !readOnly {
+		removeStaleTempFiles(path)
+	}
+
+	primaryErr := decodeSnapshot(path, data)
+	if primaryErr == nil {
+		return nil
+	}
+
+	anyBackup := false
+	for i := 1; i <= backups; i++ {
+		candidate := backupPath(path, i)
+		err := decodeSnapshot(candidate, data)
+		if err == nil {
+			log.Printf("Warning: could not load %s (%v), recovered from backup %s", path, primaryErr, candidate)
+			// Keep the damaged primary for inspection rather than letting the
+			// next snapshot rotate it into the backups.
+			if !readOnly && !os.IsNotExist(primaryErr) {
+				os.Rename(path, path+".corrupt")
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			anyBackup = true
+			log.Printf("Warning: skipping unreadable snapshot backup %s: %v", candidate, err)
+		}
+	}
+
+	if os.IsNotExist(primaryErr) && !anyBackup {
+		return nil
+	}
+	return fmt.Errorf("no valid snapshot at %s or its backups: %w", path, primaryErr)
+}
+
+func decodeSnapshot(path string, data *StorageData) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var decoded StorageData
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return err
+	}
+
+	if decoded.Users != nil {
+		data.Users = decoded.Users
+	}
+	if decoded.Items != nil {
+		data.Items = decoded.Items
+	}
+	if decoded.Categories != nil {
+		data.Categories = decoded.Categories
+	}
+	if decoded.Tags != nil {
+		data.Tags = decoded.Tags
+	}
+	if decoded.AuditLogs != nil {
+		data.AuditLogs = decoded.AuditLogs
+	}
+	if decoded.RefreshTokens != nil {
+		data.RefreshTokens = decoded.RefreshTokens
+	}
+	if decoded.Revocations != nil {
+		data.Revocations = decoded.Revocations
+	}
+	if decoded.Sessions != nil {
+		data.Sessions = decoded.Sessions
+	}
+	if decoded.Roles != nil {
+		data.Roles = decoded.Roles
+	}
+	if decoded.APIKeys != nil {
+		data.APIKeys = decoded.APIKeys
+	}
+	if decoded.TwoFactor != nil {
+		data.TwoFactor = decoded.TwoFactor
+	}
+	if decoded.LoginAttempts != nil {
+		data.LoginAttempts = decoded.LoginAttempts
+	}
+	if decoded.EmailTokens != nil {
+		data.EmailTokens = decoded.EmailTokens
+	}
+	data.Analytics = decoded.Analytics
+	return nil
+}
+
+func removeStaleTempFiles(path string) {
+	matches, _ := filepath.Glob(path + ".tmp-*")
+	for _, match := range matches {
+		os.Remove(match)
+	}
+}
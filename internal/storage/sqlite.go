@@ -0,0 +1,952 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// sqliteTimeFormat is fixed width so that timestamps stored as TEXT sort
+// chronologically.
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+const userColumns = "id, username, password, email, role, created_at, updated_at, disabled_at, must_change_password, must_verify_email"
+const itemColumns = "id, name, description, price, category_id, image_url, created_at, updated_at, created_by"
+const categoryColumns = "id, name, description, created_at, created_by"
+const tagColumns = "id, name, created_at, created_by"
+const auditLogColumns = "id, action, entity, entity_id, user_id, timestamp, details"
+
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func NewSQLiteStorage(path string, readOnly bool) (*SQLiteStorage, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if readOnly {
+		dsn += "&mode=ro"
+	} else {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; funnelling everything through one
+	// connection avoids SQLITE_BUSY under concurrent handlers.
+	db.SetMaxOpenConns(1)
+
+	if readOnly {
+		err = checkSchemaVersion(db, sqliteMigrations)
+	} else {
+		err = migrate(db, sqliteMigrations)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStorage{db: db}, nil
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+func parseTime(value string) time.Time {
+	t, err := time.Parse(sqliteTimeFormat, value)
+	if err != nil {
+		t, _ = time.Parse(time.RFC3339Nano, value)
+	}
+	return t
+}
+
+// formatOptionalTime stores an unset time as the empty string so that the
+// column can stay NOT NULL and be compared with = ”.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+func parseOptionalTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t := parseTime(value)
+	return &t
+}
+
+func scanUser(row rowScanner) (User, error) {
+	var user User
+	var createdAt, updatedAt, disabledAt string
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role, &createdAt, &updatedAt, &disabledAt, &user.MustChangePassword, &user.MustVerifyEmail)
+	user.CreatedAt = parseTime(createdAt)
+	user.UpdatedAt = parseTime(updatedAt)
+	user.DisabledAt = parseOptionalTime(disabledAt)
+	return user, err
+}
+
+func scanItem(row rowScanner) (Item, error) {
+	var item Item
+	var createdAt, updatedAt string
+	err := row.Scan(&item.ID, &item.Name, &item.Description, &item.Price, &item.CategoryID,
+		&item.ImageURL, &createdAt, &updatedAt, &item.CreatedBy)
+	item.CreatedAt = parseTime(createdAt)
+	item.UpdatedAt = parseTime(updatedAt)
+	return item, err
+}
+
+func scanCategory(row rowScanner) (Category, error) {
+	var category Category
+	var createdAt string
+	err := row.Scan(&category.ID, &category.Name, &category.Description, &createdAt, &category.CreatedBy)
+	category.CreatedAt = parseTime(createdAt)
+	return category, err
+}
+
+func scanTag(row rowScanner) (Tag, error) {
+	var tag Tag
+	var createdAt string
+	err := row.Scan(&tag.ID, &tag.Name, &createdAt, &tag.CreatedBy)
+	tag.CreatedAt = parseTime(createdAt)
+	return tag, err
+}
+
+func scanAuditLog(row rowScanner) (AuditLog, error) {
+	var entry AuditLog
+	var timestamp string
+	err := row.Scan(&entry.ID, &entry.Action, &entry.Entity, &entry.EntityID, &entry.UserID, &timestamp, &entry.Details)
+	entry.Timestamp = parseTime(timestamp)
+	return entry, err
+}
+
+func (s *SQLiteStorage) queryUsers(query string, args ...interface{}) []User {
+	users := []User{}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Println("sqlite: query users:", err)
+		return users
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			log.Println("sqlite: scan user:", err)
+			continue
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+func (s *SQLiteStorage) queryItems(query string, args ...interface{}) []Item {
+	items := []Item{}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Println("sqlite: query items:", err)
+		return items
+	}
+
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			log.Println("sqlite: scan item:", err)
+			continue
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	if err := s.loadItemTags(items); err != nil {
+		log.Println("sqlite: load item tags:", err)
+	}
+	return items
+}
+
+// loadItemTags fills Item.Tags for every item in items, preserving the order
+// the tags were attached in.
+func (s *SQLiteStorage) loadItemTags(items []Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(items))
+	args := make([]interface{}, len(items))
+	for i, item := range items {
+		index[item.ID] = i
+		args[i] = item.ID
+	}
+
+	rows, err := s.db.Query(
+		"SELECT item_id, tag_id FROM item_tags WHERE item_id IN ("+placeholders(len(items))+") ORDER BY item_id, position",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID, tagID string
+		if err := rows.Scan(&itemID, &tagID); err != nil {
+			return err
+		}
+		i := index[itemID]
+		items[i].Tags = append(items[i].Tags, tagID)
+	}
+	return rows.Err()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (s *SQLiteStorage) GetUser(id string) (User, error) {
+	user, err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, errors.New("user not found")
+	}
+	return user, err
+}
+
+func (s *SQLiteStorage) GetUserByUsername(username string) (User, error) {
+	user, err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, errors.New("user not found")
+	}
+	return user, err
+}
+
+func (s *SQLiteStorage) GetUserByEmail(email string) (User, error) {
+	user, err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE lower(email) = lower(?) LIMIT 1", email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, errors.New("user not found")
+	}
+	return user, err
+}
+
+func (s *SQLiteStorage) CreateUser(user User) error {
+	_, err := s.db.Exec(
+		"INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.Username, user.Password, user.Email, user.Role,
+		formatTime(user.CreatedAt), formatTime(user.UpdatedAt), formatOptionalTime(user.DisabledAt), user.MustChangePassword, user.MustVerifyEmail,
+	)
+	return err
+}
+
+func (s *SQLiteStorage) UpdateUser(user User) error {
+	result, err := s.db.Exec(
+		"UPDATE users SET username = ?, password = ?, email = ?, role = ?, created_at = ?, updated_at = ?, disabled_at = ?, must_change_password = ?, must_verify_email = ? WHERE id = ?",
+		user.Username, user.Password, user.Email, user.Role,
+		formatTime(user.CreatedAt), formatTime(time.Now()), formatOptionalTime(user.DisabledAt), user.MustChangePassword, user.MustVerifyEmail, user.ID,
+	)
+	return requireRow(result, err, "user not found")
+}
+
+func (s *SQLiteStorage) DeleteUser(id string, reassignTo string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if reassignTo != "" {
+		var exists int
+		err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE id = ? AND id != ?", reassignTo, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return errors.New("reassignment user not found")
+		}
+	}
+
+	// Refresh tokens and sessions go with the user through ON DELETE CASCADE.
+	result, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err := requireRow(result, err, "user not found"); err != nil {
+		return err
+	}
+
+	now := formatTime(time.Now())
+	var statements []string
+	var args [][]interface{}
+	if reassignTo != "" {
+		statements = []string{
+			"UPDATE items SET created_by = ?, updated_at = ? WHERE created_by = ?",
+			"UPDATE categories SET created_by = ? WHERE created_by = ?",
+			"UPDATE tags SET created_by = ? WHERE created_by = ?",
+			"UPDATE audit_logs SET user_id = ? WHERE user_id = ?",
+		}
+		args = [][]interface{}{{reassignTo, now, id}, {reassignTo, id}, {reassignTo, id}, {reassignTo, id}}
+	} else {
+		statements = []string{
+			"DELETE FROM items WHERE created_by = ?",
+			"UPDATE items SET category_id = '', updated_at = ? WHERE category_id IN (SELECT id FROM categories WHERE created_by = ?)",
+			"DELETE FROM categories WHERE created_by = ?",
+			"UPDATE items SET updated_at = ? WHERE id IN (SELECT item_id FROM item_tags WHERE tag_id IN (SELECT id FROM tags WHERE created_by = ?))",
+			"DELETE FROM tags WHERE created_by = ?",
+			"DELETE FROM audit_logs WHERE user_id = ?",
+		}
+		args = [][]interface{}{{id}, {now, id}, {id}, {now, id}, {id}, {id}}
+	}
+
+	for i, statement := range statements {
+		if _, err := tx.Exec(statement, args[i]...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) ListUsers() []User {
+	return s.queryUsers("SELECT " + userColumns + " FROM users ORDER BY created_at, id")
+}
+
+func (s *SQLiteStorage) UpdateUserRole(id string, role string) error {
+	if _, err := s.GetRole(role); err != nil {
+		if _, err := s.GetUser(id); err != nil {
+			return err
+		}
+		return errors.New("invalid role")
+	}
+
+	result, err := s.db.Exec(
+		"UPDATE users SET role = ?, updated_at = ? WHERE id = ?",
+		role, formatTime(time.Now()), id,
+	)
+	return requireRow(result, err, "user not found")
+}
+
+func (s *SQLiteStorage) SearchUsers(query string) []User {
+	users := s.queryUsers(
+		"SELECT "+userColumns+" FROM users WHERE instr(lower(username), lower(?)) > 0 OR instr(lower(email), lower(?)) > 0",
+		query, query,
+	)
+	for i := range users {
+		users[i].Password = ""
+	}
+	return users
+}
+
+func (s *SQLiteStorage) GetUsersPaginated(params PaginationParams) PaginatedResult {
+	where, args := userFilterConditions(params.Filter)
+	clause := whereClause(where)
+	total := s.count("SELECT COUNT(*) FROM users"+clause, args...)
+	params, offset, totalPages := normalisePage(params, total)
+
+	users := s.queryUsers(
+		"SELECT "+userColumns+" FROM users"+clause+orderBy(userSortColumns, params)+" LIMIT ? OFFSET ?",
+		append(args, params.PerPage, offset)...,
+	)
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	return PaginatedResult{
+		Data:       users,
+		Page:       params.Page,
+		PerPage:    params.PerPage,
+		TotalItems: total,
+		TotalPages: totalPages,
+	}
+}
+
+func (s *SQLiteStorage) GetItem(id string) (Item, error) {
+	item, err := scanItem(s.db.QueryRow("SELECT "+itemColumns+" FROM items WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Item{}, errors.New("item not found")
+	}
+	if err != nil {
+		return Item{}, err
+	}
+
+	items := []Item{item}
+	if err := s.loadItemTags(items); err != nil {
+		return Item{}, err
+	}
+	return items[0], nil
+}
+
+func (s *SQLiteStorage) CreateItem(item Item) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO items ("+itemColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		item.ID, item.Name, item.Description, item.Price, item.CategoryID, item.ImageURL,
+		formatTime(item.CreatedAt), formatTime(item.UpdatedAt), item.CreatedBy,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceItemTags(tx, item.ID, item.Tags); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) UpdateItem(item Item) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE items SET name = ?, description = ?, price = ?, category_id = ?, image_url = ?, created_at = ?, updated_at = ?, created_by = ? WHERE id = ?",
+		item.Name, item.Description, item.Price, item.CategoryID, item.ImageURL,
+		formatTime(item.CreatedAt), formatTime(time.Now()), item.CreatedBy, item.ID,
+	)
+	if err := requireRow(result, err, "item not found"); err != nil {
+		return err
+	}
+
+	if err := replaceItemTags(tx, item.ID, item.Tags); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceItemTags(tx *sql.Tx, itemID string, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM item_tags WHERE item_id = ?", itemID); err != nil {
+		return err
+	}
+
+	for i, tagID := range tags {
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO item_tags (item_id, tag_id, position) VALUES (?, ?, ?)",
+			itemID, tagID, i,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteItem(id string) error {
+	result, err := s.db.Exec("DELETE FROM items WHERE id = ?", id)
+	return requireRow(result, err, "item not found")
+}
+
+func (s *SQLiteStorage) ListItems() []Item {
+	return s.queryItems("SELECT " + itemColumns + " FROM items ORDER BY created_at, id")
+}
+
+func (s *SQLiteStorage) SearchItems(query string) []Item {
+	return s.queryItems(
+		"SELECT "+itemColumns+" FROM items WHERE instr(lower(name), lower(?)) > 0 OR instr(lower(description), lower(?)) > 0",
+		query, query,
+	)
+}
+
+func (s *SQLiteStorage) GetItemsByCategory(categoryID string) []Item {
+	return s.queryItems("SELECT "+itemColumns+" FROM items WHERE category_id = ?", categoryID)
+}
+
+func (s *SQLiteStorage) GetItemsByTag(tagID string) []Item {
+	return s.queryItems(
+		"SELECT "+itemColumns+" FROM items WHERE id IN (SELECT item_id FROM item_tags WHERE tag_id = ?)",
+		tagID,
+	)
+}
+
+func (s *SQLiteStorage) GetItemsPaginated(params PaginationParams) PaginatedResult {
+	where, args := itemFilterConditions(params.Filter)
+	clause := whereClause(where)
+	total := s.count("SELECT COUNT(*) FROM items"+clause, args...)
+	params, offset, totalPages := normalisePage(params, total)
+
+	items := s.queryItems(
+		"SELECT "+itemColumns+" FROM items"+clause+orderBy(itemSortColumns, params)+" LIMIT ? OFFSET ?",
+		append(args, params.PerPage, offset)...,
+	)
+
+	return PaginatedResult{
+		Data:       items,
+		Page:       params.Page,
+		PerPage:    params.PerPage,
+		TotalItems: total,
+		TotalPages: totalPages,
+	}
+}
+
+func (s *SQLiteStorage) CreateCategory(category Category) error {
+	_, err := s.db.Exec(
+		"INSERT INTO categories ("+categoryColumns+") VALUES (?, ?, ?, ?, ?)",
+		category.ID, category.Name, category.Description, formatTime(category.CreatedAt), category.CreatedBy,
+	)
+	return uniqueViolation(err, ErrCategoryNameTaken)
+}
+
+func (s *SQLiteStorage) GetCategory(id string) (Category, error) {
+	category, err := scanCategory(s.db.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Category{}, errors.New("category not found")
+	}
+	return category, err
+}
+
+func (s *SQLiteStorage) UpdateCategory(category Category) error {
+	result, err := s.db.Exec(
+		"UPDATE categories SET name = ?, description = ?, created_at = ?, created_by = ? WHERE id = ?",
+		category.Name, category.Description, formatTime(category.CreatedAt), category.CreatedBy, category.ID,
+	)
+	return requireRow(result, uniqueViolation(err, ErrCategoryNameTaken), "category not found")
+}
+
+func (s *SQLiteStorage) GetCategoryByName(name string) (Category, error) {
+	category, err := scanCategory(s.db.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE name = ? COLLATE NOCASE", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Category{}, errors.New("category not found")
+	}
+	return category, err
+}
+
+func (s *SQLiteStorage) DeleteCategory(id string, reassignTo string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if reassignTo != "" {
+		var exists int
+		err := tx.QueryRow("SELECT COUNT(*) FROM categories WHERE id = ?", reassignTo).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return errors.New("reassignment category not found")
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM categories WHERE id = ?", id)
+	if err := requireRow(result, err, "category not found"); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE items SET category_id = ?, updated_at = ? WHERE category_id = ?",
+		reassignTo, formatTime(time.Now()), id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) ListCategories() []Category {
+	categories := []Category{}
+
+	rows, err := s.db.Query("SELECT " + categoryColumns + " FROM categories ORDER BY name")
+	if err != nil {
+		log.Println("sqlite: list categories:", err)
+		return categories
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			log.Println("sqlite: scan category:", err)
+			continue
+		}
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+func (s *SQLiteStorage) CreateTag(tag Tag) error {
+	_, err := s.db.Exec(
+		"INSERT INTO tags ("+tagColumns+") VALUES (?, ?, ?, ?)",
+		tag.ID, tag.Name, formatTime(tag.CreatedAt), tag.CreatedBy,
+	)
+	return uniqueViolation(err, ErrTagNameTaken)
+}
+
+func (s *SQLiteStorage) GetTag(id string) (Tag, error) {
+	tag, err := scanTag(s.db.QueryRow("SELECT "+tagColumns+" FROM tags WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Tag{}, errors.New("tag not found")
+	}
+	return tag, err
+}
+
+func (s *SQLiteStorage) GetTagByName(name string) (Tag, error) {
+	tag, err := scanTag(s.db.QueryRow("SELECT "+tagColumns+" FROM tags WHERE name = ? COLLATE NOCASE", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Tag{}, errors.New("tag not found")
+	}
+	return tag, err
+}
+
+func (s *SQLiteStorage) UpdateTag(tag Tag) error {
+	result, err := s.db.Exec(
+		"UPDATE tags SET name = ?, created_at = ?, created_by = ? WHERE id = ?",
+		tag.Name, formatTime(tag.CreatedAt), tag.CreatedBy, tag.ID,
+	)
+	return requireRow(result, uniqueViolation(err, ErrTagNameTaken), "tag not found")
+}
+
+// DeleteTag removes the tag; item_tags rows referencing it are removed by the
+// foreign key cascade.
+func (s *SQLiteStorage) DeleteTag(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE items SET updated_at = ? WHERE id IN (SELECT item_id FROM item_tags WHERE tag_id = ?)",
+		formatTime(time.Now()), id,
+	)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err := requireRow(result, err, "tag not found"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) AddItemTag(itemID, tagID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE items SET updated_at = ? WHERE id = ?", formatTime(time.Now()), itemID)
+	if err := requireRow(result, err, "item not found"); err != nil {
+		return err
+	}
+
+	var exists int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tags WHERE id = ?", tagID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return errors.New("tag not found")
+	}
+
+	_, err = tx.Exec(
+		`INSERT OR IGNORE INTO item_tags (item_id, tag_id, position)
+		SELECT ?, ?, COALESCE(MAX(position), -1) + 1 FROM item_tags WHERE item_id = ?`,
+		itemID, tagID, itemID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) RemoveItemTag(itemID, tagID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE items SET updated_at = ? WHERE id = ?", formatTime(time.Now()), itemID)
+	if err := requireRow(result, err, "item not found"); err != nil {
+		return err
+	}
+
+	result, err = tx.Exec("DELETE FROM item_tags WHERE item_id = ? AND tag_id = ?", itemID, tagID)
+	if err := requireRow(result, err, "tag not attached to item"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) ListTags() []Tag {
+	tags := []Tag{}
+
+	rows, err := s.db.Query("SELECT " + tagColumns + " FROM tags ORDER BY name")
+	if err != nil {
+		log.Println("sqlite: list tags:", err)
+		return tags
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			log.Println("sqlite: scan tag:", err)
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func (s *SQLiteStorage) CreateAuditLog(entry AuditLog) error {
+	_, err := s.db.Exec(
+		"INSERT INTO audit_logs ("+auditLogColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		entry.ID, entry.Action, entry.Entity, entry.EntityID, entry.UserID, formatTime(entry.Timestamp), entry.Details,
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetAuditLogs(limit int) []AuditLog {
+	query := "SELECT " + auditLogColumns + " FROM audit_logs ORDER BY timestamp DESC, id DESC"
+	args := []interface{}{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	return s.queryAuditLogs(query, args...)
+}
+
+func (s *SQLiteStorage) queryAuditLogs(query string, args ...interface{}) []AuditLog {
+	logs := []AuditLog{}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Println("sqlite: query audit logs:", err)
+		return logs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			log.Println("sqlite: scan audit log:", err)
+			continue
+		}
+		logs = append(logs, entry)
+	}
+	return logs
+}
+
+func (s *SQLiteStorage) UpdateAnalytics() error {
+	analytics := Analytics{
+		TotalUsers:      s.count("SELECT COUNT(*) FROM users"),
+		TotalItems:      s.count("SELECT COUNT(*) FROM items"),
+		TotalCategories: s.count("SELECT COUNT(*) FROM categories"),
+		TotalTags:       s.count("SELECT COUNT(*) FROM tags"),
+		PopularCategories: s.names(`SELECT c.name FROM items i JOIN categories c ON c.id = i.category_id
+			GROUP BY c.id ORDER BY COUNT(*) DESC, c.name LIMIT 5`),
+		PopularTags: s.names(`SELECT t.name FROM item_tags it JOIN tags t ON t.id = it.tag_id
+			GROUP BY t.id ORDER BY COUNT(*) DESC, t.name LIMIT 5`),
+		RecentActivities: s.getRecentActivities(10),
+		UpdatedAt:        time.Now(),
+	}
+
+	data, err := json.Marshal(analytics)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("INSERT INTO analytics (id, data) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET data = excluded.data", string(data))
+	return err
+}
+
+func (s *SQLiteStorage) GetAnalytics() Analytics {
+	var data string
+	if err := s.db.QueryRow("SELECT data FROM analytics WHERE id = 1").Scan(&data); err != nil {
+		return Analytics{}
+	}
+
+	var analytics Analytics
+	json.Unmarshal([]byte(data), &analytics)
+	return analytics
+}
+
+func (s *SQLiteStorage) getRecentActivities(limit int) []string {
+	logs := s.GetAuditLogs(limit)
+
+	activities := make([]string, len(logs))
+	for i, entry := range logs {
+		username := "Unknown"
+		if user, err := s.GetUser(entry.UserID); err == nil {
+			username = user.Username
+		}
+
+		activities[i] = formatActivity(entry, username)
+	}
+
+	return activities
+}
+
+func (s *SQLiteStorage) count(query string, args ...interface{}) int {
+	var n int
+	if err := s.db.QueryRow(query, args...).Scan(&n); err != nil {
+		log.Println("sqlite: count:", err)
+	}
+	return n
+}
+
+func (s *SQLiteStorage) names(query string, args ...interface{}) []string {
+	names := []string{}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Println("sqlite: query names:", err)
+		return names
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// userFilterConditions translates the user filters understood by
+// GetUsersPaginated into SQL conditions and their arguments.
+func userFilterConditions(filters map[string]string) ([]string, []interface{}) {
+	where, args := []string{}, []interface{}{}
+	for key, value := range filters {
+		switch key {
+		case "role":
+			where = append(where, "role = ?")
+			args = append(args, value)
+		case "q":
+			where = append(where, "(instr(lower(username), lower(?)) > 0 OR instr(lower(email), lower(?)) > 0)")
+			args = append(args, value, value)
+		}
+	}
+
+	return where, args
+}
+
+// itemFilterConditions translates the item filters understood by
+// GetItemsPaginated into SQL conditions and their arguments.
+func itemFilterConditions(filters map[string]string) ([]string, []interface{}) {
+	where, args := []string{}, []interface{}{}
+	for key, value := range filters {
+		switch key {
+		case "category_id":
+			where = append(where, "category_id = ?")
+			args = append(args, value)
+		case "tag":
+			where = append(where, "id IN (SELECT item_id FROM item_tags WHERE tag_id = ?)")
+			args = append(args, value)
+		case "created_by":
+			where = append(where, "created_by = ?")
+			args = append(args, value)
+		case "min_price":
+			if min, err := strconv.ParseFloat(value, 64); err == nil {
+				where = append(where, "price >= ?")
+				args = append(args, min)
+			}
+		case "max_price":
+			if max, err := strconv.ParseFloat(value, 64); err == nil {
+				where = append(where, "price <= ?")
+				args = append(args, max)
+			}
+		case "q":
+			where = append(where, "(instr(lower(name), lower(?)) > 0 OR instr(lower(description), lower(?)) > 0)")
+			args = append(args, value, value)
+		}
+	}
+
+	return where, args
+}
+
+// itemSortColumns and userSortColumns map the public sort fields onto SQL
+// expressions; anything not listed falls back to created_at.
+var itemSortColumns = map[string]string{
+	"name":       "name COLLATE NOCASE",
+	"price":      "price",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+var userSortColumns = map[string]string{
+	"name":       "username COLLATE NOCASE",
+	"email":      "email COLLATE NOCASE",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+func orderBy(columns map[string]string, params PaginationParams) string {
+	column, ok := columns[params.SortBy]
+	if !ok {
+		column = "created_at"
+	}
+
+	direction := " ASC"
+	if params.SortDesc {
+		direction = " DESC"
+	}
+	return " ORDER BY " + column + direction + ", id" + direction
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// uniqueViolation returns taken in place of err when err is a violation of
+// a unique index, such as the one on category names.
+func uniqueViolation(err error, taken error) error {
+	if errors.Is(err, sqlite3.CONSTRAINT_UNIQUE) {
+		return taken
+	}
+	return err
+}
+
+func requireRow(result sql.Result, err error, notFound string) error {
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New(notFound)
+	}
+	return nil
+}
+
+var _ Store = (*SQLiteStorage)(nil)
@@ -3,27 +3,41 @@ package storage
 import (
 	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
 const (
-	RoleAdmin  = "admin"
-	RoleUser   = "user"
-	MaxPerPage = 25
+	RoleAdmin      = "admin"
+	RoleUser       = "user"
+	DefaultPerPage = 25
+	MaxPerPage     = 100
 )
 
 type User struct {
 	ID        string    `json:"id"`
 	Username  string    `json:"username"`
-	Password  string    `json:"password"`
+	Password  string    `json:"password,omitempty"`
 	Email     string    `json:"email"`
 	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// DisabledAt is set while an admin has disabled the account.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// MustChangePassword limits the user to changing their password, for
+	// passwords that someone else has chosen.
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+	// MustVerifyEmail is set from registration or an email change until the
+	// user follows the link sent to Email.
+	MustVerifyEmail bool `json:"must_verify_email,omitempty"`
+}
+
+// Disabled reports whether the account may not log in or use its tokens.
+func (u User) Disabled() bool {
+	return u.DisabledAt != nil
 }
 
 type Category struct {
@@ -34,6 +48,10 @@ type Category struct {
 	CreatedBy   string    `json:"created_by"`
 }
 
+// ErrCategoryNameTaken is returned when a category would get the name of
+// another category. Names are compared case-insensitively.
+var ErrCategoryNameTaken = errors.New("category name already exists")
+
 type Tag struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
@@ -41,6 +59,10 @@ type Tag struct {
 	CreatedBy string    `json:"created_by"`
 }
 
+// ErrTagNameTaken is returned when a tag would get the name of another tag.
+// Names are compared case-insensitively.
+var ErrTagNameTaken = errors.New("tag name already exists")
+
 type Item struct {
 	ID          string    `json:"id"`
 	Name        string    `json:"name"`
@@ -89,14 +111,36 @@ type StorageData struct {
 	Tags       map[string]Tag      `json:"tags"`
 	AuditLogs  map[string]AuditLog `json:"audit_logs"`
 	Analytics  Analytics           `json:"analytics"`
+
+	RefreshTokens map[string]RefreshToken `json:"refresh_tokens"`
+	Revocations   map[string]Revocation   `json:"revocations"`
+	Sessions      map[string]Session      `json:"sessions"`
+	Roles         map[string]Role         `json:"roles"`
+	APIKeys       map[string]APIKey       `json:"api_keys"`
+	TwoFactor     map[string]TwoFactor    `json:"two_factor"`
+	LoginAttempts map[string]LoginAttempt `json:"login_attempts"`
+	EmailTokens   map[string]EmailToken   `json:"email_tokens"`
 }
 
-type Storage struct {
+// JSONStorage keeps the whole dataset in memory. Mutations are appended to a
+// write-ahead log next to the snapshot file and periodically compacted into a
+// new snapshot, which is written atomically with rotated backups.
+type JSONStorage struct {
 	data     StorageData
 	filePath string
+	backups  int
+	readOnly bool
+	wal      *writeAheadLog
 	mu       sync.RWMutex
 }
 
+// ItemSortFields and UserSortFields are the values accepted for
+// PaginationParams.SortBy; an empty SortBy sorts by created_at.
+var (
+	ItemSortFields = []string{"name", "price", "created_at", "updated_at"}
+	UserSortFields = []string{"name", "email", "created_at", "updated_at"}
+)
+
 type PaginationParams struct {
 	Page     int
 	PerPage  int
@@ -113,52 +157,185 @@ type PaginatedResult struct {
 	TotalPages int         `json:"total_pages"`
 }
 
-func NewStorage() *Storage {
-	s := &Storage{
-		filePath: "./pkg/storage/storage.json",
+func NewJSONStorage(filePath string, readOnly bool) (*JSONStorage, error) {
+	s := &JSONStorage{
+		filePath: filePath,
+		readOnly: readOnly,
+		backups:  DefaultSnapshotBackups,
 		data: StorageData{
 			Users:      make(map[string]User),
 			Items:      make(map[string]Item),
 			Categories: make(map[string]Category),
 			Tags:       make(map[string]Tag),
 			AuditLogs:  make(map[string]AuditLog),
+
+			RefreshTokens: make(map[string]RefreshToken),
+			Revocations:   make(map[string]Revocation),
+			Sessions:      make(map[string]Session),
+			Roles:         make(map[string]Role),
+			APIKeys:       make(map[string]APIKey),
+			TwoFactor:     make(map[string]TwoFactor),
+			LoginAttempts: make(map[string]LoginAttempt),
+			EmailTokens:   make(map[string]EmailToken),
 			Analytics: Analytics{
 				UpdatedAt: time.Now(),
 			},
 		},
 	}
-	s.loadData()
-	return s
+
+	if err := s.loadData(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-func (s *Storage) loadData() error {
+// loadData reads the snapshot, replays any write-ahead log records made since
+// it was taken and compacts them into a fresh snapshot.
+func (s *JSONStorage) loadData() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	data, err := ioutil.ReadFile(s.filePath)
+	if err := readSnapshot(s.filePath, s.backups, s.readOnly, &s.data); err != nil {
+		return err
+	}
+
+	wal, err := openWAL(s.walPath(), s.readOnly)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return s.saveData()
-		}
 		return err
 	}
+	s.wal = wal
 
-	return json.Unmarshal(data, &s.data)
-}
+	if err := s.wal.replay(s.applyRecord); err != nil {
+		return err
+	}
 
-func (s *Storage) saveData() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	if s.readOnly {
+		return nil
+	}
+	return s.compact()
+}
 
+// saveData must be called with s.mu held.
+func (s *JSONStorage) saveData() error {
 	data, err := json.MarshalIndent(s.data, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return ioutil.WriteFile(s.filePath, data, 0644)
+	return writeSnapshot(s.filePath, data, s.backups)
+}
+
+func (s *JSONStorage) walPath() string {
+	return s.filePath + ".wal"
+}
+
+// compact writes a snapshot of the current data and empties the log. It must
+// be called with s.mu held.
+func (s *JSONStorage) compact() error {
+	if err := s.saveData(); err != nil {
+		return err
+	}
+	return s.wal.reset()
+}
+
+// Compact folds the write-ahead log into the snapshot file.
+func (s *JSONStorage) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	return s.compact()
 }
 
-func (s *Storage) GetUser(id string) (User, error) {
+func (s *JSONStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.readOnly {
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+	return s.wal.close()
+}
+
+// put and remove log a mutation and then apply it to s.data. They must be
+// called with s.mu held.
+func (s *JSONStorage) put(collection, id string, value interface{}) error {
+	record, err := putRecord(collection, id, value)
+	if err != nil {
+		return err
+	}
+	return s.persist(record)
+}
+
+func (s *JSONStorage) remove(collection, id string) error {
+	return s.persist(deleteRecord(collection, id))
+}
+
+// persist logs records and then applies them to s.data. It must be called
+// with s.mu held.
+func (s *JSONStorage) persist(records ...walRecord) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := s.wal.append(records...); err != nil {
+		return err
+	}
+
+	// s.data only changes once the log holds the records, so a failed write
+	// leaves memory matching what a restart would load.
+	for _, record := range records {
+		if err := s.applyRecord(record); err != nil {
+			return err
+		}
+	}
+
+	if s.wal.entries >= walCompactThreshold {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *JSONStorage) applyRecord(record walRecord) error {
+	switch record.Collection {
+	case "users":
+		return applyRecord(s.data.Users, record)
+	case "items":
+		return applyRecord(s.data.Items, record)
+	case "categories":
+		return applyRecord(s.data.Categories, record)
+	case "tags":
+		return applyRecord(s.data.Tags, record)
+	case "audit_logs":
+		return applyRecord(s.data.AuditLogs, record)
+	case "analytics":
+		return json.Unmarshal(record.Data, &s.data.Analytics)
+	case "refresh_tokens":
+		return applyRecord(s.data.RefreshTokens, record)
+	case "revocations":
+		return applyRecord(s.data.Revocations, record)
+	case "sessions":
+		return applyRecord(s.data.Sessions, record)
+	case "roles":
+		return applyRecord(s.data.Roles, record)
+	case "api_keys":
+		return applyRecord(s.data.APIKeys, record)
+	case "two_factor":
+		return applyRecord(s.data.TwoFactor, record)
+	case "login_attempts":
+		return applyRecord(s.data.LoginAttempts, record)
+	case "email_tokens":
+		return applyRecord(s.data.EmailTokens, record)
+	}
+	return errors.New("unknown wal collection: " + record.Collection)
+}
+
+func (s *JSONStorage) GetUser(id string) (User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -169,7 +346,7 @@ func (s *Storage) GetUser(id string) (User, error) {
 	return user, nil
 }
 
-func (s *Storage) GetUserByUsername(username string) (User, error) {
+func (s *JSONStorage) GetUserByUsername(username string) (User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -181,15 +358,35 @@ func (s *Storage) GetUserByUsername(username string) (User, error) {
 	return User{}, errors.New("user not found")
 }
 
-func (s *Storage) CreateUser(user User) error {
+func (s *JSONStorage) GetUserByEmail(email string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.data.Users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return User{}, errors.New("user not found")
+}
+
+func (s *JSONStorage) CreateUser(user User) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data.Users[user.ID] = user
-	return s.saveData()
+	if _, exists := s.data.Users[user.ID]; exists {
+		return errors.New("user already exists")
+	}
+	for _, existing := range s.data.Users {
+		if existing.Username == user.Username {
+			return errors.New("username already exists")
+		}
+	}
+
+	return s.put("users", user.ID, user)
 }
 
-func (s *Storage) UpdateUser(user User) error {
+func (s *JSONStorage) UpdateUser(user User) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -198,23 +395,150 @@ func (s *Storage) UpdateUser(user User) error {
 	}
 
 	user.UpdatedAt = time.Now()
-	s.data.Users[user.ID] = user
-	return s.saveData()
+	return s.put("users", user.ID, user)
 }
 
-func (s *Storage) DeleteUser(id string) error {
+func (s *JSONStorage) DeleteUser(id string, reassignTo string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.data.Users[id]; !exists {
 		return errors.New("user not found")
 	}
+	if reassignTo != "" {
+		if _, exists := s.data.Users[reassignTo]; !exists || reassignTo == id {
+			return errors.New("reassignment user not found")
+		}
+	}
+
+	now := time.Now()
+	records := []walRecord{}
+	// Items can change in several of the passes below, so their new state is
+	// collected here and logged once at the end.
+	changedItems := map[string]Item{}
+	deletedItems := map[string]bool{}
+	currentItem := func(itemID string) Item {
+		if item, changed := changedItems[itemID]; changed {
+			return item
+		}
+		return s.data.Items[itemID]
+	}
+
+	for itemID, item := range s.data.Items {
+		if item.CreatedBy != id {
+			continue
+		}
+		if reassignTo == "" {
+			deletedItems[itemID] = true
+			records = append(records, deleteRecord("items", itemID))
+			continue
+		}
+		item.CreatedBy = reassignTo
+		item.UpdatedAt = now
+		changedItems[itemID] = item
+	}
 
-	delete(s.data.Users, id)
-	return s.saveData()
+	for categoryID, category := range s.data.Categories {
+		if category.CreatedBy != id {
+			continue
+		}
+		if reassignTo == "" {
+			records = append(records, deleteRecord("categories", categoryID))
+			for itemID := range s.data.Items {
+				item := currentItem(itemID)
+				if !deletedItems[itemID] && item.CategoryID == categoryID {
+					item.CategoryID = ""
+					item.UpdatedAt = now
+					changedItems[itemID] = item
+				}
+			}
+			continue
+		}
+		category.CreatedBy = reassignTo
+		record, err := putRecord("categories", categoryID, category)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	for tagID, tag := range s.data.Tags {
+		if tag.CreatedBy != id {
+			continue
+		}
+		if reassignTo == "" {
+			records = append(records, deleteRecord("tags", tagID))
+			for itemID := range s.data.Items {
+				item := currentItem(itemID)
+				tags := withoutTag(item.Tags, tagID)
+				if !deletedItems[itemID] && len(tags) != len(item.Tags) {
+					item.Tags = tags
+					item.UpdatedAt = now
+					changedItems[itemID] = item
+				}
+			}
+			continue
+		}
+		tag.CreatedBy = reassignTo
+		record, err := putRecord("tags", tagID, tag)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	for itemID, item := range changedItems {
+		record, err := putRecord("items", itemID, item)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	for logID, log := range s.data.AuditLogs {
+		if log.UserID != id {
+			continue
+		}
+		if reassignTo == "" {
+			records = append(records, deleteRecord("audit_logs", logID))
+			continue
+		}
+		log.UserID = reassignTo
+		record, err := putRecord("audit_logs", logID, log)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	for tokenID, token := range s.data.RefreshTokens {
+		if token.UserID == id {
+			records = append(records, deleteRecord("refresh_tokens", tokenID))
+		}
+	}
+	for sessionID, session := range s.data.Sessions {
+		if session.UserID == id {
+			records = append(records, deleteRecord("sessions", sessionID))
+		}
+	}
+	for keyID, key := range s.data.APIKeys {
+		if key.UserID == id {
+			records = append(records, deleteRecord("api_keys", keyID))
+		}
+	}
+	if _, exists := s.data.TwoFactor[id]; exists {
+		records = append(records, deleteRecord("two_factor", id))
+	}
+	for tokenID, token := range s.data.EmailTokens {
+		if token.UserID == id {
+			records = append(records, deleteRecord("email_tokens", tokenID))
+		}
+	}
+
+	return s.persist(append(records, deleteRecord("users", id))...)
 }
 
-func (s *Storage) ListUsers() []User {
+func (s *JSONStorage) ListUsers() []User {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -225,7 +549,7 @@ func (s *Storage) ListUsers() []User {
 	return users
 }
 
-func (s *Storage) UpdateUserRole(id string, role string) error {
+func (s *JSONStorage) UpdateUserRole(id string, role string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -234,17 +558,16 @@ func (s *Storage) UpdateUserRole(id string, role string) error {
 		return errors.New("user not found")
 	}
 
-	if role != RoleAdmin && role != RoleUser {
+	if _, exists := s.data.Roles[role]; !exists {
 		return errors.New("invalid role")
 	}
 
 	user.Role = role
 	user.UpdatedAt = time.Now()
-	s.data.Users[id] = user
-	return s.saveData()
+	return s.put("users", id, user)
 }
 
-func (s *Storage) SearchUsers(query string) []User {
+func (s *JSONStorage) SearchUsers(query string) []User {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -260,21 +583,14 @@ func (s *Storage) SearchUsers(query string) []User {
 	return result
 }
 
-func (s *Storage) GetUsersPaginated(params PaginationParams) PaginatedResult {
+func (s *JSONStorage) GetUsersPaginated(params PaginationParams) PaginatedResult {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	users := s.getFilteredUsers(params.Filter)
 	total := len(users)
 
-	if params.PerPage <= 0 {
-		params.PerPage = MaxPerPage
-	}
-	if params.Page <= 0 {
-		params.Page = 1
-	}
-
-	start := (params.Page - 1) * params.PerPage
+	params, start, totalPages := normalisePage(params, total)
 	end := start + params.PerPage
 	if end > total {
 		end = total
@@ -283,11 +599,6 @@ func (s *Storage) GetUsersPaginated(params PaginationParams) PaginatedResult {
 		start = total
 	}
 
-	totalPages := (total + params.PerPage - 1) / params.PerPage
-	if totalPages < 1 {
-		totalPages = 1
-	}
-
 	sortUsers(users, params.SortBy, params.SortDesc)
 
 	pagedUsers := []User{}
@@ -308,7 +619,7 @@ func (s *Storage) GetUsersPaginated(params PaginationParams) PaginatedResult {
 	}
 }
 
-func (s *Storage) getFilteredUsers(filters map[string]string) []User {
+func (s *JSONStorage) getFilteredUsers(filters map[string]string) []User {
 	users := make([]User, 0, len(s.data.Users))
 
 	for _, user := range s.data.Users {
@@ -320,6 +631,10 @@ func (s *Storage) getFilteredUsers(filters map[string]string) []User {
 				if user.Role != value {
 					match = false
 				}
+			case "q":
+				if !containsInsensitive(user.Username, value) && !containsInsensitive(user.Email, value) {
+					match = false
+				}
 			}
 		}
 
@@ -331,7 +646,7 @@ func (s *Storage) getFilteredUsers(filters map[string]string) []User {
 	return users
 }
 
-func (s *Storage) GetItem(id string) (Item, error) {
+func (s *JSONStorage) GetItem(id string) (Item, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -342,15 +657,14 @@ func (s *Storage) GetItem(id string) (Item, error) {
 	return item, nil
 }
 
-func (s *Storage) CreateItem(item Item) error {
+func (s *JSONStorage) CreateItem(item Item) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data.Items[item.ID] = item
-	return s.saveData()
+	return s.put("items", item.ID, item)
 }
 
-func (s *Storage) UpdateItem(item Item) error {
+func (s *JSONStorage) UpdateItem(item Item) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -359,11 +673,10 @@ func (s *Storage) UpdateItem(item Item) error {
 	}
 
 	item.UpdatedAt = time.Now()
-	s.data.Items[item.ID] = item
-	return s.saveData()
+	return s.put("items", item.ID, item)
 }
 
-func (s *Storage) DeleteItem(id string) error {
+func (s *JSONStorage) DeleteItem(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -371,11 +684,10 @@ func (s *Storage) DeleteItem(id string) error {
 		return errors.New("item not found")
 	}
 
-	delete(s.data.Items, id)
-	return s.saveData()
+	return s.remove("items", id)
 }
 
-func (s *Storage) ListItems() []Item {
+func (s *JSONStorage) ListItems() []Item {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -386,7 +698,7 @@ func (s *Storage) ListItems() []Item {
 	return items
 }
 
-func (s *Storage) SearchItems(query string) []Item {
+func (s *JSONStorage) SearchItems(query string) []Item {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -400,7 +712,7 @@ func (s *Storage) SearchItems(query string) []Item {
 	return result
 }
 
-func (s *Storage) GetItemsByCategory(categoryID string) []Item {
+func (s *JSONStorage) GetItemsByCategory(categoryID string) []Item {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -413,7 +725,7 @@ func (s *Storage) GetItemsByCategory(categoryID string) []Item {
 	return result
 }
 
-func (s *Storage) GetItemsByTag(tagID string) []Item {
+func (s *JSONStorage) GetItemsByTag(tagID string) []Item {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -429,21 +741,14 @@ func (s *Storage) GetItemsByTag(tagID string) []Item {
 	return result
 }
 
-func (s *Storage) GetItemsPaginated(params PaginationParams) PaginatedResult {
+func (s *JSONStorage) GetItemsPaginated(params PaginationParams) PaginatedResult {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	items := s.getFilteredItems(params.Filter)
 	total := len(items)
 
-	if params.PerPage <= 0 {
-		params.PerPage = MaxPerPage
-	}
-	if params.Page <= 0 {
-		params.Page = 1
-	}
-
-	start := (params.Page - 1) * params.PerPage
+	params, start, totalPages := normalisePage(params, total)
 	end := start + params.PerPage
 	if end > total {
 		end = total
@@ -452,11 +757,6 @@ func (s *Storage) GetItemsPaginated(params PaginationParams) PaginatedResult {
 		start = total
 	}
 
-	totalPages := (total + params.PerPage - 1) / params.PerPage
-	if totalPages < 1 {
-		totalPages = 1
-	}
-
 	sortItems(items, params.SortBy, params.SortDesc)
 
 	pagedItems := []Item{}
@@ -473,7 +773,7 @@ func (s *Storage) GetItemsPaginated(params PaginationParams) PaginatedResult {
 	}
 }
 
-func (s *Storage) getFilteredItems(filters map[string]string) []Item {
+func (s *JSONStorage) getFilteredItems(filters map[string]string) []Item {
 	items := make([]Item, 0, len(s.data.Items))
 
 	for _, item := range s.data.Items {
@@ -496,6 +796,22 @@ func (s *Storage) getFilteredItems(filters map[string]string) []Item {
 				if !found {
 					match = false
 				}
+			case "created_by":
+				if item.CreatedBy != value {
+					match = false
+				}
+			case "min_price":
+				if min, err := strconv.ParseFloat(value, 64); err == nil && item.Price < min {
+					match = false
+				}
+			case "max_price":
+				if max, err := strconv.ParseFloat(value, 64); err == nil && item.Price > max {
+					match = false
+				}
+			case "q":
+				if !containsInsensitive(item.Name, value) && !containsInsensitive(item.Description, value) {
+					match = false
+				}
 			}
 		}
 
@@ -507,15 +823,18 @@ func (s *Storage) getFilteredItems(filters map[string]string) []Item {
 	return items
 }
 
-func (s *Storage) CreateCategory(category Category) error {
+func (s *JSONStorage) CreateCategory(category Category) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data.Categories[category.ID] = category
-	return s.saveData()
+	if s.categoryNameTaken(category.Name, category.ID) {
+		return ErrCategoryNameTaken
+	}
+
+	return s.put("categories", category.ID, category)
 }
 
-func (s *Storage) GetCategory(id string) (Category, error) {
+func (s *JSONStorage) GetCategory(id string) (Category, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -526,31 +845,76 @@ func (s *Storage) GetCategory(id string) (Category, error) {
 	return category, nil
 }
 
-func (s *Storage) UpdateCategory(category Category) error {
+func (s *JSONStorage) UpdateCategory(category Category) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.data.Categories[category.ID]; !exists {
 		return errors.New("category not found")
 	}
+	if s.categoryNameTaken(category.Name, category.ID) {
+		return ErrCategoryNameTaken
+	}
 
-	s.data.Categories[category.ID] = category
-	return s.saveData()
+	return s.put("categories", category.ID, category)
 }
 
-func (s *Storage) DeleteCategory(id string) error {
+// categoryNameTaken reports whether a category other than id is called name.
+// It must be called with s.mu held.
+func (s *JSONStorage) categoryNameTaken(name string, id string) bool {
+	for _, category := range s.data.Categories {
+		if category.ID != id && strings.EqualFold(category.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *JSONStorage) GetCategoryByName(name string) (Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, category := range s.data.Categories {
+		if strings.EqualFold(category.Name, name) {
+			return category, nil
+		}
+	}
+	return Category{}, errors.New("category not found")
+}
+
+func (s *JSONStorage) DeleteCategory(id string, reassignTo string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.data.Categories[id]; !exists {
 		return errors.New("category not found")
 	}
+	if reassignTo != "" {
+		if _, exists := s.data.Categories[reassignTo]; !exists {
+			return errors.New("reassignment category not found")
+		}
+	}
+
+	records := []walRecord{}
+	for itemID, item := range s.data.Items {
+		if item.CategoryID != id {
+			continue
+		}
+
+		item.CategoryID = reassignTo
+		item.UpdatedAt = time.Now()
+		record, err := putRecord("items", itemID, item)
+		if err != nil {
+			return err
+		}
 
-	delete(s.data.Categories, id)
-	return s.saveData()
+		records = append(records, record)
+	}
+
+	return s.persist(append(records, deleteRecord("categories", id))...)
 }
 
-func (s *Storage) ListCategories() []Category {
+func (s *JSONStorage) ListCategories() []Category {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -561,15 +925,18 @@ func (s *Storage) ListCategories() []Category {
 	return categories
 }
 
-func (s *Storage) CreateTag(tag Tag) error {
+func (s *JSONStorage) CreateTag(tag Tag) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data.Tags[tag.ID] = tag
-	return s.saveData()
+	if s.tagNameTaken(tag.Name, tag.ID) {
+		return ErrTagNameTaken
+	}
+
+	return s.put("tags", tag.ID, tag)
 }
 
-func (s *Storage) GetTag(id string) (Tag, error) {
+func (s *JSONStorage) GetTag(id string) (Tag, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -580,7 +947,45 @@ func (s *Storage) GetTag(id string) (Tag, error) {
 	return tag, nil
 }
 
-func (s *Storage) DeleteTag(id string) error {
+func (s *JSONStorage) GetTagByName(name string) (Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, tag := range s.data.Tags {
+		if strings.EqualFold(tag.Name, name) {
+			return tag, nil
+		}
+	}
+	return Tag{}, errors.New("tag not found")
+}
+
+func (s *JSONStorage) UpdateTag(tag Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.Tags[tag.ID]; !exists {
+		return errors.New("tag not found")
+	}
+	if s.tagNameTaken(tag.Name, tag.ID) {
+		return ErrTagNameTaken
+	}
+
+	return s.put("tags", tag.ID, tag)
+}
+
+// tagNameTaken reports whether a tag other than id is called name. It must
+// be called with s.mu held.
+func (s *JSONStorage) tagNameTaken(name string, id string) bool {
+	for _, tag := range s.data.Tags {
+		if tag.ID != id && strings.EqualFold(tag.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteTag removes the tag and detaches it from every item that carries it.
+func (s *JSONStorage) DeleteTag(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -588,11 +993,69 @@ func (s *Storage) DeleteTag(id string) error {
 		return errors.New("tag not found")
 	}
 
-	delete(s.data.Tags, id)
-	return s.saveData()
+	records := []walRecord{}
+	for itemID, item := range s.data.Items {
+		tags := withoutTag(item.Tags, id)
+		if len(tags) == len(item.Tags) {
+			continue
+		}
+
+		item.Tags = tags
+		item.UpdatedAt = time.Now()
+		record, err := putRecord("items", itemID, item)
+		if err != nil {
+			return err
+		}
+
+		records = append(records, record)
+	}
+
+	return s.persist(append(records, deleteRecord("tags", id))...)
+}
+
+func (s *JSONStorage) AddItemTag(itemID, tagID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.data.Items[itemID]
+	if !exists {
+		return errors.New("item not found")
+	}
+	if _, exists := s.data.Tags[tagID]; !exists {
+		return errors.New("tag not found")
+	}
+
+	for _, tag := range item.Tags {
+		if tag == tagID {
+			return nil
+		}
+	}
+
+	item.Tags = append(append([]string{}, item.Tags...), tagID)
+	item.UpdatedAt = time.Now()
+	return s.put("items", itemID, item)
 }
 
-func (s *Storage) ListTags() []Tag {
+func (s *JSONStorage) RemoveItemTag(itemID, tagID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.data.Items[itemID]
+	if !exists {
+		return errors.New("item not found")
+	}
+
+	tags := withoutTag(item.Tags, tagID)
+	if len(tags) == len(item.Tags) {
+		return errors.New("tag not attached to item")
+	}
+
+	item.Tags = tags
+	item.UpdatedAt = time.Now()
+	return s.put("items", itemID, item)
+}
+
+func (s *JSONStorage) ListTags() []Tag {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -603,18 +1066,21 @@ func (s *Storage) ListTags() []Tag {
 	return tags
 }
 
-func (s *Storage) CreateAuditLog(log AuditLog) error {
+func (s *JSONStorage) CreateAuditLog(log AuditLog) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data.AuditLogs[log.ID] = log
-	return s.saveData()
+	return s.put("audit_logs", log.ID, log)
 }
 
-func (s *Storage) GetAuditLogs(limit int) []AuditLog {
+func (s *JSONStorage) GetAuditLogs(limit int) []AuditLog {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	return s.getAuditLogs(limit)
+}
+
+func (s *JSONStorage) getAuditLogs(limit int) []AuditLog {
 	logs := make([]AuditLog, 0, len(s.data.AuditLogs))
 	for _, log := range s.data.AuditLogs {
 		logs = append(logs, log)
@@ -629,7 +1095,7 @@ func (s *Storage) GetAuditLogs(limit int) []AuditLog {
 	return logs
 }
 
-func (s *Storage) UpdateAnalytics() {
+func (s *JSONStorage) UpdateAnalytics() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -644,17 +1110,16 @@ func (s *Storage) UpdateAnalytics() {
 		UpdatedAt:         time.Now(),
 	}
 
-	s.data.Analytics = analytics
-	s.saveData()
+	return s.put("analytics", "", analytics)
 }
 
-func (s *Storage) GetAnalytics() Analytics {
+func (s *JSONStorage) GetAnalytics() Analytics {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.data.Analytics
 }
 
-func (s *Storage) getPopularCategories(limit int) []string {
+func (s *JSONStorage) getPopularCategories(limit int) []string {
 	categoryCounts := make(map[string]int)
 	for _, item := range s.data.Items {
 		categoryCounts[item.CategoryID]++
@@ -677,7 +1142,7 @@ func (s *Storage) getPopularCategories(limit int) []string {
 	return result
 }
 
-func (s *Storage) getPopularTags(limit int) []string {
+func (s *JSONStorage) getPopularTags(limit int) []string {
 	tagCounts := make(map[string]int)
 	for _, item := range s.data.Items {
 		for _, tagID := range item.Tags {
@@ -702,8 +1167,8 @@ func (s *Storage) getPopularTags(limit int) []string {
 	return result
 }
 
-func (s *Storage) getRecentActivities(limit int) []string {
-	logs := s.GetAuditLogs(limit)
+func (s *JSONStorage) getRecentActivities(limit int) []string {
+	logs := s.getAuditLogs(limit)
 
 	activities := make([]string, len(logs))
 	for i, log := range logs {
@@ -720,18 +1185,120 @@ func (s *Storage) getRecentActivities(limit int) []string {
 	return activities
 }
 
+// normalisePage applies the page defaults shared by every backend and returns
+// the row offset and total page count for total matching rows.
+func normalisePage(params PaginationParams, total int) (PaginationParams, int, int) {
+	if params.PerPage <= 0 {
+		params.PerPage = DefaultPerPage
+	}
+	if params.PerPage > MaxPerPage {
+		params.PerPage = MaxPerPage
+	}
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+
+	totalPages := (total + params.PerPage - 1) / params.PerPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	return params, (params.Page - 1) * params.PerPage, totalPages
+}
+
 func containsInsensitive(s, substr string) bool {
 	s, substr = strings.ToLower(s), strings.ToLower(substr)
 	return strings.Contains(s, substr)
 }
 
+func withoutTag(tags []string, tagID string) []string {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag != tagID {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// sortUsers orders users by sortBy (one of UserSortFields, created_at when
+// empty), breaking ties on ID so that pages are stable between requests.
 func sortUsers(users []User, sortBy string, desc bool) {
+	sort.SliceStable(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+
+		var c int
+		switch sortBy {
+		case "name":
+			c = compareFold(a.Username, b.Username)
+		case "email":
+			c = compareFold(a.Email, b.Email)
+		case "updated_at":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		default:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
 }
 
+// sortItems orders items by sortBy (one of ItemSortFields, created_at when
+// empty), breaking ties on ID so that pages are stable between requests.
 func sortItems(items []Item, sortBy string, desc bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+
+		var c int
+		switch sortBy {
+		case "name":
+			c = compareFold(a.Name, b.Name)
+		case "price":
+			c = compareFloat(a.Price, b.Price)
+		case "updated_at":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		default:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
 }
 
+// sortAuditLogs orders logs newest first.
 func sortAuditLogs(logs []AuditLog) {
+	sort.SliceStable(logs, func(i, j int) bool {
+		if c := logs[i].Timestamp.Compare(logs[j].Timestamp); c != 0 {
+			return c > 0
+		}
+		return logs[i].ID > logs[j].ID
+	})
+}
+
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
 }
 
 func sortByCounts[T interface {
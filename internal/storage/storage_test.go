@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func openJSON(t *testing.T, path string) *JSONStorage {
+	t.Helper()
+
+	s, err := NewJSONStorage(path, false)
+	if err != nil {
+		t.Fatalf("NewJSONStorage: %v", err)
+	}
+	return s
+}
+
+// crash drops s without compacting, as if the process had died, so that
+// reopening the path has to replay the write-ahead log.
+func crash(s *JSONStorage) {
+	s.wal.close()
+}
+
+func userIDs(s Store) []string {
+	ids := []string{}
+	for _, user := range s.ListUsers() {
+		ids = append(ids, user.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func testUser(id string) User {
+	now := time.Now()
+	return User{ID: id, Username: id, Email: id + "@example.com", Role: RoleUser, CreatedAt: now, UpdatedAt: now}
+}
+
+func TestJSONStorageReplaysLogAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s := openJSON(t, path)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		if err := s.CreateUser(testUser(id)); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	if err := s.DeleteUser("bob", ""); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	crash(s)
+
+	s = openJSON(t, path)
+	defer s.Close()
+
+	if got, want := userIDs(s), []string{"alice", "carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users after replay = %v, want %v", got, want)
+	}
+}
+
+func TestJSONStorageLogDamage(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  string
+		wantErr bool
+	}{
+		{"torn final record", `{"op":"put","collection":"users","id":"dave","da`, false},
+		{"corrupt record before the end", "garbage\n" + `{"op":"delete","collection":"users","id":"alice"}` + "\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage.json")
+
+			s := openJSON(t, path)
+			if err := s.CreateUser(testUser("alice")); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			crash(s)
+
+			log, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log.WriteString(tt.damage)
+			log.Close()
+
+			s, err = NewJSONStorage(path, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewJSONStorage error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer s.Close()
+
+			if got, want := userIDs(s), []string{"alice"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("users after replay = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONStorageRecoversFromBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s := openJSON(t, path)
+	if err := s.CreateUser(testUser("alice")); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if err := s.CreateUser(testUser("bob")); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"users": {`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openJSON(t, path)
+	defer s.Close()
+
+	if got, want := userIDs(s), []string{"alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users after recovery = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Errorf("damaged snapshot was not kept: %v", err)
+	}
+}
+
+func TestJSONStorageReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s := openJSON(t, path)
+	if err := s.CreateUser(testUser("alice")); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	crash(s)
+
+	torn := `{"op":"pu`
+	log, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.WriteString(torn)
+	log.Close()
+	before, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := NewStorage(Options{Path: path, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer ro.Close()
+
+	if got, want := userIDs(ro), []string{"alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users = %v, want %v", got, want)
+	}
+	if err := ro.CreateUser(testUser("bob")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("CreateUser error = %v, want %v", err, ErrReadOnly)
+	}
+
+	after, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("read-only open changed the log from %q to %q", before, after)
+	}
+}
+
+func TestJSONStorageFailedWriteLeavesDataUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s := openJSON(t, path)
+	alice := testUser("alice")
+	if err := s.CreateUser(alice); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	stored, err := s.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+
+	// Every further log append fails.
+	s.wal.file.Close()
+
+	renamed := stored
+	renamed.Username = "alicia"
+
+	tests := []struct {
+		name   string
+		mutate func() error
+	}{
+		{"create", func() error { return s.CreateUser(testUser("bob")) }},
+		{"update", func() error { return s.UpdateUser(renamed) }},
+		{"change role", func() error { return s.UpdateUserRole("alice", RoleAdmin) }},
+		{"delete", func() error { return s.DeleteUser("alice", "") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.mutate(); err == nil {
+				t.Fatal("mutation succeeded without a log")
+			}
+
+			if got, want := userIDs(s), []string{"alice"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("users = %v, want %v", got, want)
+			}
+			got, err := s.GetUser("alice")
+			if err != nil {
+				t.Fatalf("GetUser: %v", err)
+			}
+			if !reflect.DeepEqual(got, stored) {
+				t.Errorf("GetUser = %+v, want %+v", got, stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import "time"
+
+// Store is the persistence contract the API handlers and middleware depend on.
+// JSONStorage is the default implementation; alternative backends only need to
+// satisfy this interface to be plugged into api.RegisterRoutes.
+type Store interface {
+	GetUser(id string) (User, error)
+	GetUserByUsername(username string) (User, error)
+	// GetUserByEmail matches email case-insensitively.
+	GetUserByEmail(email string) (User, error)
+	CreateUser(user User) error
+	UpdateUser(user User) error
+	// DeleteUser hands the user's items, categories, tags and audit log
+	// entries to reassignTo, or deletes them when reassignTo is empty.
+	DeleteUser(id string, reassignTo string) error
+	ListUsers() []User
+	// UpdateUserRole fails with "invalid role" unless the role exists.
+	UpdateUserRole(id string, role string) error
+	SearchUsers(query string) []User
+	GetUsersPaginated(params PaginationParams) PaginatedResult
+	GetUsersByCursor(params CursorParams) CursorPage
+
+	GetItem(id string) (Item, error)
+	CreateItem(item Item) error
+	UpdateItem(item Item) error
+	DeleteItem(id string) error
+	ListItems() []Item
+	SearchItems(query string) []Item
+	GetItemsByCategory(categoryID string) []Item
+	GetItemsByTag(tagID string) []Item
+	GetItemsPaginated(params PaginationParams) PaginatedResult
+	GetItemsByCursor(params CursorParams) CursorPage
+
+	CreateCategory(category Category) error
+	GetCategory(id string) (Category, error)
+	GetCategoryByName(name string) (Category, error)
+	UpdateCategory(category Category) error
+	// DeleteCategory moves the category's items to reassignTo, or leaves them
+	// without a category when reassignTo is empty.
+	DeleteCategory(id string, reassignTo string) error
+	ListCategories() []Category
+
+	CreateTag(tag Tag) error
+	GetTag(id string) (Tag, error)
+	GetTagByName(name string) (Tag, error)
+	UpdateTag(tag Tag) error
+	// DeleteTag also detaches the tag from every item carrying it.
+	DeleteTag(id string) error
+	ListTags() []Tag
+	AddItemTag(itemID, tagID string) error
+	RemoveItemTag(itemID, tagID string) error
+
+	CreateAuditLog(log AuditLog) error
+	GetAuditLogs(limit int) []AuditLog
+	GetAuditLogsByCursor(params CursorParams) CursorPage
+
+	UpdateAnalytics() error
+	GetAnalytics() Analytics
+
+	CreateRefreshToken(token RefreshToken) error
+	GetRefreshToken(id string) (RefreshToken, error)
+	// RotateRefreshToken marks token id as used and stores next in a single
+	// step. It returns ErrTokenReused if id was already used or revoked.
+	RotateRefreshToken(id string, next RefreshToken) error
+	RevokeRefreshTokenFamily(familyID string) error
+	DeleteExpiredRefreshTokens(before time.Time) (int, error)
+	RevokeUserRefreshTokens(userID string) error
+
+	// PutRevocation creates or replaces the revocation with the same ID.
+	PutRevocation(revocation Revocation) error
+	GetRevocation(id string) (Revocation, error)
+	DeleteExpiredRevocations(before time.Time) (int, error)
+
+	CreateSession(session Session) error
+	GetSession(id string) (Session, error)
+	// ListUserSessions returns every stored session of the user, most
+	// recently seen first.
+	ListUserSessions(userID string) []Session
+	// TouchSession, ExtendSession and RevokeSession leave revoked sessions
+	// unchanged.
+	TouchSession(id string, seenAt time.Time) error
+	ExtendSession(id string, expiresAt time.Time) error
+	RevokeSession(id string) error
+	RevokeUserSessions(userID string) error
+	DeleteExpiredSessions(before time.Time) (int, error)
+
+	CreateRole(role Role) error
+	GetRole(name string) (Role, error)
+	UpdateRole(role Role) error
+	// DeleteRole refuses to delete a role that is still assigned to a user.
+	DeleteRole(name string) error
+	ListRoles() []Role
+
+	CreateAPIKey(key APIKey) error
+	GetAPIKey(id string) (APIKey, error)
+	GetAPIKeyByHash(hash string) (APIKey, error)
+	// ListUserAPIKeys returns every key of the user, newest first.
+	ListUserAPIKeys(userID string) []APIKey
+	TouchAPIKey(id string, usedAt time.Time) error
+	// RevokeAPIKey keeps the first revocation time of a key revoked twice.
+	RevokeAPIKey(id string) error
+
+	// PutTwoFactor creates or replaces the enrollment of tf.UserID.
+	PutTwoFactor(tf TwoFactor) error
+	GetTwoFactor(userID string) (TwoFactor, error)
+	DeleteTwoFactor(userID string) error
+	// UseTwoFactorStep records TOTP time step as used. It returns
+	// ErrTwoFactorCodeUsed unless step is newer than every step used before.
+	UseTwoFactorStep(userID string, step int64) error
+	// UseRecoveryCode removes the recovery code with hash, returning
+	// ErrTwoFactorCodeUsed if the user has no such unused code.
+	UseRecoveryCode(userID string, hash string) error
+
+	// RecordLoginFailure adds a failure to the login attempt id, creating it
+	// or starting a new count if its last failure was before resetBefore, and
+	// returns the updated attempt.
+	RecordLoginFailure(id string, at time.Time, resetBefore time.Time) (LoginAttempt, error)
+	GetLoginAttempt(id string) (LoginAttempt, error)
+	// ListLoginAttempts returns every stored attempt, latest failure first.
+	ListLoginAttempts() []LoginAttempt
+	DeleteLoginAttempt(id string) error
+	DeleteExpiredLoginAttempts(before time.Time) (int, error)
+
+	CreateEmailToken(token EmailToken) error
+	GetEmailToken(id string) (EmailToken, error)
+	// UseEmailToken marks token id as used, returning ErrEmailTokenUsed if it
+	// already was.
+	UseEmailToken(id string) error
+	// DeleteUserEmailTokens deletes every token of the user for purpose.
+	DeleteUserEmailTokens(userID string, purpose string) error
+	DeleteExpiredEmailTokens(before time.Time) (int, error)
+
+	Close() error
+}
+
+var _ Store = (*JSONStorage)(nil)
@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// eachBackend runs test against a fresh store of every backend. open opens
+// the same store again, so a test can check what survives a restart; every
+// store it returns is closed at the end of the test.
+func eachBackend(t *testing.T, test func(t *testing.T, open func() Store)) {
+	for _, backend := range []string{BackendJSON, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage."+backend)
+			test(t, func() Store {
+				t.Helper()
+
+				s, err := NewStorage(Options{Path: path, Backend: backend})
+				if err != nil {
+					t.Fatalf("NewStorage: %v", err)
+				}
+				t.Cleanup(func() { s.Close() })
+				return s
+			})
+		})
+	}
+}
+
+// must fails the test if err is not nil.
+func must(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testTime is a fixed point that every backend stores exactly.
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestStoreUsers(t *testing.T) {
+	eachBackend(t, func(t *testing.T, open func() Store) {
+		s := open()
+
+		alice := testUser("alice")
+		alice.Email = "Alice@Example.com"
+		must(t, s.CreateUser(alice))
+		must(t, s.CreateUser(testUser("bob")))
+
+		if err := s.CreateUser(testUser("alice")); err == nil {
+			t.Error("created a second user with ID alice")
+		}
+		other := testUser("carol")
+		other.Username = "bob"
+		if err := s.CreateUser(other); err == nil {
+			t.Error("created a second user named bob")
+		}
+
+		got, err := s.GetUserByEmail("alice@example.COM")
+		if err != nil || got.ID != "alice" {
+			t.Errorf("GetUserByEmail = %+v, %v; want alice", got, err)
+		}
+		if _, err := s.GetUserByUsername("carol"); err == nil {
+			t.Error("GetUserByUsername found carol")
+		}
+
+		alice.Email = "alice@example.org"
+		alice.MustChangePassword = true
+		must(t, s.UpdateUser(alice))
+		if err := s.UpdateUser(testUser("carol")); err == nil {
+			t.Error("updated the missing user carol")
+		}
+
+		must(t, s.DeleteUser("bob", ""))
+		if err := s.DeleteUser("bob", ""); err == nil {
+			t.Error("deleted bob twice")
+		}
+
+		s.Close()
+		s = open()
+
+		got, err = s.GetUserByUsername("alice")
+		if err != nil || got.Email != "alice@example.org" || !got.MustChangePassword {
+			t.Errorf("alice after reopening = %+v, %v", got, err)
+		}
+		if ids, want := userIDs(s), []string{"alice"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("users = %v, want %v", ids, want)
+		}
+	})
+}
+
+func TestStoreItems(t *testing.T) {
+	eachBackend(t, func(t *testing.T, open func() Store) {
+		s := open()
+
+		must(t, s.CreateUser(testUser("alice")))
+		must(t, s.CreateCategory(Category{ID: "lamps", Name: "Lamps", CreatedBy: "alice", CreatedAt: testTime}))
+		must(t, s.CreateCategory(Category{ID: "lights", Name: "Lights", CreatedBy: "alice", CreatedAt: testTime}))
+		must(t, s.CreateTag(Tag{ID: "sale", Name: "sale", CreatedBy: "alice", CreatedAt: testTime}))
+		must(t, s.CreateItem(Item{ID: "desk-lamp", Name: "Desk lamp", Price: 25, CategoryID: "lamps", CreatedBy: "alice", CreatedAt: testTime, UpdatedAt: testTime}))
+		must(t, s.CreateItem(Item{ID: "floor-lamp", Name: "Floor lamp", Price: 80, CategoryID: "lamps", CreatedBy: "alice", CreatedAt: testTime, UpdatedAt: testTime}))
+
+		must(t, s.AddItemTag("desk-lamp", "sale"))
+		if err := s.AddItemTag("desk-lamp", "missing"); err == nil {
+			t.Error("attached a missing tag")
+		}
+		if items := s.GetItemsByTag("sale"); len(items) != 1 || items[0].ID != "desk-lamp" {
+			t.Errorf("items tagged sale = %+v", items)
+		}
+
+		item, err := s.GetItem("floor-lamp")
+		must(t, err)
+		item.Price = 70
+		must(t, s.UpdateItem(item))
+		must(t, s.DeleteItem("desk-lamp"))
+		if _, err := s.GetItem("desk-lamp"); err == nil {
+			t.Error("desk-lamp still exists")
+		}
+
+		must(t, s.DeleteCategory("lamps", "lights"))
+		must(t, s.DeleteTag("sale"))
+
+		s.Close()
+		s = open()
+
+		item, err = s.GetItem("floor-lamp")
+		if err != nil || item.Price != 70 || item.CategoryID != "lights" {
+			t.Errorf("floor-lamp after reopening = %+v, %v", item, err)
+		}
+		if items := s.GetItemsByCategory("lights"); len(items) != 1 {
+			t.Errorf("items in lights = %+v", items)
+		}
+		if tags := s.ListTags(); len(tags) != 0 {
+			t.Errorf("tags = %+v, want none", tags)
+		}
+	})
+}
+
+func TestStoreRotateRefreshToken(t *testing.T) {
+	eachBackend(t, func(t *testing.T, open func() Store) {
+		s := open()
+		must(t, s.CreateUser(testUser("alice")))
+
+		token := func(id string) RefreshToken {
+			return RefreshToken{ID: id, UserID: "alice", FamilyID: "login", CreatedAt: testTime, ExpiresAt: testTime.Add(time.Hour)}
+		}
+		must(t, s.CreateRefreshToken(token("first")))
+		if err := s.CreateRefreshToken(token("first")); err == nil {
+			t.Error("created refresh token first twice")
+		}
+
+		must(t, s.RotateRefreshToken("first", token("second")))
+		if err := s.RotateRefreshToken("first", token("third")); !errors.Is(err, ErrTokenReused) {
+			t.Errorf("rotating first again: %v, want ErrTokenReused", err)
+		}
+		if _, err := s.GetRefreshToken("third"); err == nil {
+			t.Error("a failed rotation stored its token")
+		}
+
+		first, err := s.GetRefreshToken("first")
+		if err != nil || first.UsedAt == nil {
+			t.Errorf("first after rotation = %+v, %v; want it used", first, err)
+		}
+
+		must(t, s.RevokeRefreshTokenFamily("login"))
+		if err := s.RotateRefreshToken("second", token("third")); !errors.Is(err, ErrTokenReused) {
+			t.Errorf("rotating a revoked token: %v, want ErrTokenReused", err)
+		}
+
+		s.Close()
+		s = open()
+
+		second, err := s.GetRefreshToken("second")
+		if err != nil || second.UsedAt != nil || second.RevokedAt == nil {
+			t.Errorf("second after reopening = %+v, %v; want it revoked and unused", second, err)
+		}
+	})
+}
+
+func TestStoreDeleteUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		reassignTo string
+		// wantItems maps each remaining item to its creator, category and
+		// tags.
+		wantItems map[string]Item
+		wantCats  []string
+		wantTags  []string
+	}{
+		{
+			name: "cascade",
+			wantItems: map[string]Item{
+				"bob-item": {CreatedBy: "bob", CategoryID: "", Tags: []string{"bob-tag"}},
+			},
+			wantCats: []string{"bob-cat"},
+			wantTags: []string{"bob-tag"},
+		},
+		{
+			name:       "reassign",
+			reassignTo: "bob",
+			wantItems: map[string]Item{
+				"alice-item": {CreatedBy: "bob", CategoryID: "alice-cat", Tags: []string{"alice-tag"}},
+				"bob-item":   {CreatedBy: "bob", CategoryID: "alice-cat", Tags: []string{"bob-tag", "alice-tag"}},
+			},
+			wantCats: []string{"alice-cat", "bob-cat"},
+			wantTags: []string{"alice-tag", "bob-tag"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eachBackend(t, func(t *testing.T, open func() Store) {
+				s := open()
+
+				must(t, s.CreateUser(testUser("alice")))
+				must(t, s.CreateUser(testUser("bob")))
+				must(t, s.CreateCategory(Category{ID: "alice-cat", Name: "alice-cat", CreatedBy: "alice", CreatedAt: testTime}))
+				must(t, s.CreateCategory(Category{ID: "bob-cat", Name: "bob-cat", CreatedBy: "bob", CreatedAt: testTime}))
+				must(t, s.CreateTag(Tag{ID: "alice-tag", Name: "alice-tag", CreatedBy: "alice", CreatedAt: testTime}))
+				must(t, s.CreateTag(Tag{ID: "bob-tag", Name: "bob-tag", CreatedBy: "bob", CreatedAt: testTime}))
+				must(t, s.CreateItem(Item{ID: "alice-item", Name: "alice-item", CreatedBy: "alice", CategoryID: "alice-cat", Tags: []string{"alice-tag"}, CreatedAt: testTime}))
+				must(t, s.CreateItem(Item{ID: "bob-item", Name: "bob-item", CreatedBy: "bob", CategoryID: "alice-cat", Tags: []string{"bob-tag", "alice-tag"}, CreatedAt: testTime}))
+				must(t, s.CreateAuditLog(AuditLog{ID: "log", Action: "create", UserID: "alice", Timestamp: testTime}))
+				must(t, s.CreateRefreshToken(RefreshToken{ID: "token", UserID: "alice", FamilyID: "login", CreatedAt: testTime, ExpiresAt: testTime.Add(time.Hour)}))
+
+				must(t, s.DeleteUser("alice", tt.reassignTo))
+
+				check := func(s Store) {
+					t.Helper()
+
+					if _, err := s.GetUser("alice"); err == nil {
+						t.Error("alice still exists")
+					}
+					if _, err := s.GetRefreshToken("token"); err == nil {
+						t.Error("alice's refresh token still exists")
+					}
+
+					items := map[string]Item{}
+					for _, item := range s.ListItems() {
+						items[item.ID] = Item{CreatedBy: item.CreatedBy, CategoryID: item.CategoryID, Tags: item.Tags}
+					}
+					if !reflect.DeepEqual(items, tt.wantItems) {
+						t.Errorf("items = %+v, want %+v", items, tt.wantItems)
+					}
+
+					cats := []string{}
+					for _, category := range s.ListCategories() {
+						cats = append(cats, category.ID)
+					}
+					sort.Strings(cats)
+					if !reflect.DeepEqual(cats, tt.wantCats) {
+						t.Errorf("categories = %v, want %v", cats, tt.wantCats)
+					}
+
+					tags := []string{}
+					for _, tag := range s.ListTags() {
+						tags = append(tags, tag.ID)
+					}
+					sort.Strings(tags)
+					if !reflect.DeepEqual(tags, tt.wantTags) {
+						t.Errorf("tags = %v, want %v", tags, tt.wantTags)
+					}
+
+					logs := s.GetAuditLogs(0)
+					if tt.reassignTo == "" && len(logs) != 0 {
+						t.Errorf("audit logs = %+v, want none", logs)
+					}
+					if tt.reassignTo != "" && (len(logs) != 1 || logs[0].UserID != tt.reassignTo) {
+						t.Errorf("audit logs = %+v, want one by %s", logs, tt.reassignTo)
+					}
+				}
+
+				check(s)
+				s.Close()
+				check(open())
+			})
+		})
+	}
+}
+
+func TestStoreUsersByCursor(t *testing.T) {
+	eachBackend(t, func(t *testing.T, open func() Store) {
+		s := open()
+
+		// Users created at the same time are ordered by ID.
+		created := map[string]time.Time{
+			"u4": testTime.Add(time.Minute),
+			"u5": testTime,
+			"u3": testTime,
+			"u2": testTime.Add(-time.Minute),
+			"u1": testTime.Add(-time.Minute),
+		}
+		for id, at := range created {
+			user := testUser(id)
+			user.CreatedAt = at
+			must(t, s.CreateUser(user))
+		}
+		want := []string{"u1", "u2", "u3", "u5", "u4"}
+
+		ids := func(page CursorPage) []string {
+			ids := []string{}
+			for _, user := range page.Data.([]User) {
+				if user.Password != "" {
+					t.Errorf("page includes the password hash of %s", user.ID)
+				}
+				ids = append(ids, user.ID)
+			}
+			return ids
+		}
+
+		var forward []string
+		params := CursorParams{Limit: 2}
+		for pages := 0; ; pages++ {
+			if pages > len(want) {
+				t.Fatal("paging forward does not end")
+			}
+			page := s.GetUsersByCursor(params)
+			forward = append(forward, ids(page)...)
+			if page.HasPrev != (params.After != nil) {
+				t.Errorf("page after %v: HasPrev = %v", params.After, page.HasPrev)
+			}
+			if !page.HasNext {
+				break
+			}
+			params.After = page.Last
+		}
+		if !reflect.DeepEqual(forward, want) {
+			t.Errorf("paging forward = %v, want %v", forward, want)
+		}
+
+		last := CursorKey{Time: testTime.Add(time.Minute), ID: "u4"}
+		page := s.GetUsersByCursor(CursorParams{Before: &last, Limit: 2})
+		if got := ids(page); !reflect.DeepEqual(got, []string{"u3", "u5"}) || !page.HasPrev || !page.HasNext {
+			t.Errorf("page before u4 = %v (prev %v, next %v), want [u3 u5] with both", got, page.HasPrev, page.HasNext)
+		}
+	})
+}
+
+func TestStoreCategoryNamesAreUnique(t *testing.T) {
+	eachBackend(t, func(t *testing.T, open func() Store) {
+		s := open()
+		must(t, s.CreateUser(testUser("alice")))
+		category := func(id, name string) Category {
+			return Category{ID: id, Name: name, CreatedBy: "alice", CreatedAt: testTime}
+		}
+
+		must(t, s.CreateCategory(category("lamps", "Lamps")))
+		must(t, s.CreateCategory(category("books", "Books")))
+
+		if err := s.CreateCategory(category("lights", "LAMPS")); !errors.Is(err, ErrCategoryNameTaken) {
+			t.Errorf("creating a second Lamps: %v, want ErrCategoryNameTaken", err)
+		}
+		if err := s.UpdateCategory(category("books", "lamps")); !errors.Is(err, ErrCategoryNameTaken) {
+			t.Errorf("renaming Books to lamps: %v, want ErrCategoryNameTaken", err)
+		}
+		must(t, s.UpdateCategory(category("lamps", "LAMPS")))
+
+		if got := len(s.ListCategories()); got != 2 {
+			t.Errorf("%d categories, want 2", got)
+		}
+	})
+}
+
+func TestStoreTagNamesAreUnique(t *testing.T) {
+	eachBackend(t, func(t *testing.T, open func() Store) {
+		s := open()
+		must(t, s.CreateUser(testUser("alice")))
+		tag := func(id, name string) Tag {
+			return Tag{ID: id, Name: name, CreatedBy: "alice", CreatedAt: testTime}
+		}
+
+		must(t, s.CreateTag(tag("sale", "sale")))
+		must(t, s.CreateTag(tag("new", "new")))
+
+		if err := s.CreateTag(tag("discount", "SALE")); !errors.Is(err, ErrTagNameTaken) {
+			t.Errorf("creating a second sale: %v, want ErrTagNameTaken", err)
+		}
+		if err := s.UpdateTag(tag("new", "sale")); !errors.Is(err, ErrTagNameTaken) {
+			t.Errorf("renaming new to sale: %v, want ErrTagNameTaken", err)
+		}
+		must(t, s.UpdateTag(tag("sale", "Sale")))
+
+		if got := len(s.ListTags()); got != 2 {
+			t.Errorf("%d tags, want 2", got)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrTwoFactorCodeUsed is returned when a TOTP time step or recovery code is
+// presented a second time.
+var ErrTwoFactorCodeUsed = errors.New("two-factor code already used")
+
+// TwoFactor is a user's TOTP enrollment. It exists from setup on but only
+// protects logins once EnabledAt is set, after the user has proven that their
+// authenticator produces valid codes. RecoveryCodes holds hashes of the unused
+// recovery codes, and LastStep the newest TOTP time step accepted, so that no
+// code works twice.
+type TwoFactor struct {
+	UserID        string     `json:"user_id"`
+	Secret        string     `json:"secret"`
+	RecoveryCodes []string   `json:"recovery_codes"`
+	LastStep      int64      `json:"last_step"`
+	CreatedAt     time.Time  `json:"created_at"`
+	EnabledAt     *time.Time `json:"enabled_at,omitempty"`
+}
+
+// Enabled reports whether logins of the user require a second factor.
+func (tf TwoFactor) Enabled() bool {
+	return tf.EnabledAt != nil
+}
+
+const twoFactorColumns = "user_id, secret, recovery_codes, last_step, created_at, enabled_at"
+
+func (s *JSONStorage) PutTwoFactor(tf TwoFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.put("two_factor", tf.UserID, tf)
+}
+
+func (s *JSONStorage) GetTwoFactor(userID string) (TwoFactor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tf, exists := s.data.TwoFactor[userID]
+	if !exists {
+		return TwoFactor{}, errors.New("two-factor enrollment not found")
+	}
+	return tf, nil
+}
+
+func (s *JSONStorage) DeleteTwoFactor(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.TwoFactor[userID]; !exists {
+		return errors.New("two-factor enrollment not found")
+	}
+
+	return s.remove("two_factor", userID)
+}
+
+func (s *JSONStorage) UseTwoFactorStep(userID string, step int64) error {
+	return s.updateTwoFactor(userID, func(tf *TwoFactor) error {
+		if step <= tf.LastStep {
+			return ErrTwoFactorCodeUsed
+		}
+		tf.LastStep = step
+		return nil
+	})
+}
+
+func (s *JSONStorage) UseRecoveryCode(userID string, hash string) error {
+	return s.updateTwoFactor(userID, func(tf *TwoFactor) error {
+		remaining, found := withoutRecoveryCode(tf.RecoveryCodes, hash)
+		if !found {
+			return ErrTwoFactorCodeUsed
+		}
+		tf.RecoveryCodes = remaining
+		return nil
+	})
+}
+
+func (s *JSONStorage) updateTwoFactor(userID string, update func(*TwoFactor) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, exists := s.data.TwoFactor[userID]
+	if !exists {
+		return errors.New("two-factor enrollment not found")
+	}
+	if err := update(&tf); err != nil {
+		return err
+	}
+
+	return s.put("two_factor", userID, tf)
+}
+
+// withoutRecoveryCode returns codes without hash and whether it was present.
+func withoutRecoveryCode(codes []string, hash string) ([]string, bool) {
+	remaining := make([]string, 0, len(codes))
+	found := false
+	for _, code := range codes {
+		if code == hash && !found {
+			found = true
+			continue
+		}
+		remaining = append(remaining, code)
+	}
+	return remaining, found
+}
+
+func scanTwoFactor(row rowScanner) (TwoFactor, error) {
+	var tf TwoFactor
+	var recoveryCodes, createdAt, enabledAt string
+	err := row.Scan(&tf.UserID, &tf.Secret, &recoveryCodes, &tf.LastStep, &createdAt, &enabledAt)
+	if err != nil {
+		return tf, err
+	}
+
+	tf.CreatedAt = parseTime(createdAt)
+	tf.EnabledAt = parseOptionalTime(enabledAt)
+	return tf, json.Unmarshal([]byte(recoveryCodes), &tf.RecoveryCodes)
+}
+
+func (s *SQLiteStorage) PutTwoFactor(tf TwoFactor) error {
+	recoveryCodes, err := json.Marshal(tf.RecoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO two_factor ("+twoFactorColumns+") VALUES (?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, recovery_codes = excluded.recovery_codes, "+
+			"last_step = excluded.last_step, created_at = excluded.created_at, enabled_at = excluded.enabled_at",
+		tf.UserID, tf.Secret, string(recoveryCodes), tf.LastStep,
+		formatTime(tf.CreatedAt), formatOptionalTime(tf.EnabledAt),
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetTwoFactor(userID string) (TwoFactor, error) {
+	tf, err := scanTwoFactor(s.db.QueryRow("SELECT "+twoFactorColumns+" FROM two_factor WHERE user_id = ?", userID))
+	if err == sql.ErrNoRows {
+		return TwoFactor{}, errors.New("two-factor enrollment not found")
+	}
+	return tf, err
+}
+
+func (s *SQLiteStorage) DeleteTwoFactor(userID string) error {
+	result, err := s.db.Exec("DELETE FROM two_factor WHERE user_id = ?", userID)
+	return requireRow(result, err, "two-factor enrollment not found")
+}
+
+func (s *SQLiteStorage) UseTwoFactorStep(userID string, step int64) error {
+	result, err := s.db.Exec(
+		"UPDATE two_factor SET last_step = ? WHERE user_id = ? AND last_step < ?",
+		step, userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	if _, err := s.GetTwoFactor(userID); err != nil {
+		return err
+	}
+	return ErrTwoFactorCodeUsed
+}
+
+func (s *SQLiteStorage) UseRecoveryCode(userID string, hash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tf, err := scanTwoFactor(tx.QueryRow("SELECT "+twoFactorColumns+" FROM two_factor WHERE user_id = ?", userID))
+	if err == sql.ErrNoRows {
+		return errors.New("two-factor enrollment not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	remaining, found := withoutRecoveryCode(tf.RecoveryCodes, hash)
+	if !found {
+		return ErrTwoFactorCodeUsed
+	}
+
+	recoveryCodes, err := json.Marshal(remaining)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE two_factor SET recovery_codes = ? WHERE user_id = ?", string(recoveryCodes), userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
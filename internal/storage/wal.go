@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	walOpPut    = "put"
+	walOpDelete = "delete"
+	walOpBatch  = "batch"
+
+	// walCompactThreshold is the number of log records after which the JSON
+	// backend folds the log into a fresh snapshot.
+	walCompactThreshold = 1000
+)
+
+// walRecord is a single mutation of one StorageData collection, or a batch
+// of mutations in Records that must be applied together. Records are
+// idempotent, so replaying a log over a snapshot that already contains some
+// of its changes is harmless.
+type walRecord struct {
+	Op         string          `json:"op"`
+	Collection string          `json:"collection,omitempty"`
+	ID         string          `json:"id,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Records    []walRecord     `json:"records,omitempty"`
+}
+
+// writeAheadLog is an append-only file of newline-delimited walRecords. Every
+// append is fsynced before it returns.
+type writeAheadLog struct {
+	file     *os.File
+	entries  int
+	readOnly bool
+	// err is set once a failed append could not be cut off again, and is
+	// returned by every later append until the log is reset.
+	err error
+}
+
+// openWAL opens the log at path for appending. A read-only log is only ever
+// replayed; a missing read-only log behaves as an empty one.
+func openWAL(path string, readOnly bool) (*writeAheadLog, error) {
+	if readOnly {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			return &writeAheadLog{readOnly: true}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &writeAheadLog{file: file, readOnly: true}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &writeAheadLog{file: file}, nil
+}
+
+// append writes records as one line, so that replay sees either all of them
+// or, after a crash mid-append, a torn line that it drops.
+func (w *writeAheadLog) append(records ...walRecord) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	record := walRecord{Op: walOpBatch, Records: records}
+	if len(records) == 1 {
+		record = records[0]
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	size, err := w.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.file.Write(line); err != nil {
+		return w.undo(size, err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return w.undo(size, err)
+	}
+
+	w.entries += len(records)
+	return nil
+}
+
+// undo cuts the log back to size after an append failed with cause, so that
+// the next append does not follow a partial record. If that fails as well,
+// the log takes no further appends: the partial record stays last, where
+// replay drops it.
+func (w *writeAheadLog) undo(size int64, cause error) error {
+	if err := w.file.Truncate(size); err != nil {
+		w.err = fmt.Errorf("write-ahead log could not be repaired after a failed append: %w", err)
+		return errors.Join(cause, w.err)
+	}
+	return cause
+}
+
+// reset empties the log once its records are covered by a snapshot.
+func (w *writeAheadLog) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+
+	w.entries = 0
+	w.err = nil
+	return nil
+}
+
+func (w *writeAheadLog) close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+// replay feeds every complete record in the log to apply, in order, with
+// batches broken up into their records. A torn final line left by a crash
+// mid-append is discarded, whole batch and all, and truncated away so that
+// later appends start on a clean line. A line that cannot be decoded
+// anywhere else means the log is corrupt, and replay fails rather than drop
+// the mutations that follow it.
+func (w *writeAheadLog) replay(apply func(walRecord) error) error {
+	if w.file == nil {
+		return nil
+	}
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(w.file)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return w.truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var record walRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			if _, peekErr := reader.Peek(1); errors.Is(peekErr, io.EOF) {
+				return w.truncate(offset)
+			}
+			return fmt.Errorf("corrupt write-ahead log record at offset %d: %w", offset, err)
+		}
+
+		records := []walRecord{record}
+		if record.Op == walOpBatch {
+			records = record.Records
+		}
+		for _, record := range records {
+			if err := apply(record); err != nil {
+				return err
+			}
+		}
+
+		offset += int64(len(line))
+		w.entries += len(records)
+	}
+}
+
+func (w *writeAheadLog) truncate(offset int64) error {
+	if w.readOnly {
+		return nil
+	}
+	return w.file.Truncate(offset)
+}
+
+func putRecord(collection, id string, value interface{}) (walRecord, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return walRecord{}, err
+	}
+	return walRecord{Op: walOpPut, Collection: collection, ID: id, Data: data}, nil
+}
+
+func deleteRecord(collection, id string) walRecord {
+	return walRecord{Op: walOpDelete, Collection: collection, ID: id}
+}
+
+func applyRecord[T any](m map[string]T, record walRecord) error {
+	switch record.Op {
+	case walOpPut:
+		var value T
+		if err := json.Unmarshal(record.Data, &value); err != nil {
+			return err
+		}
+		m[record.ID] = value
+	case walOpDelete:
+		delete(m, record.ID)
+	default:
+		return errors.New("unknown wal operation: " + record.Op)
+	}
+	return nil
+}
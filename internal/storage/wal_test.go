@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWALReplay(t *testing.T) {
+	const (
+		first  = `{"op":"put","collection":"users","id":"a","data":{"id":"a"}}` + "\n"
+		second = `{"op":"delete","collection":"users","id":"a"}` + "\n"
+		batch  = `{"op":"batch","records":[{"op":"put","collection":"users","id":"b","data":{"id":"b"}},{"op":"delete","collection":"users","id":"a"}]}` + "\n"
+	)
+
+	tests := []struct {
+		name      string
+		log       string
+		readOnly  bool
+		wantCount int
+		wantErr   bool
+		// wantLog is the log file's content after replay.
+		wantLog string
+	}{
+		{"missing", "", false, 0, false, ""},
+		{"complete", first + second, false, 2, false, first + second},
+		{"torn final record", first + `{"op":"pu`, false, 1, false, first},
+		{"unterminated final record", first + second[:len(second)-1], false, 1, false, first},
+		{"undecodable final record", first + "garbage\n", false, 1, false, first},
+		{"corrupt record before the end", first + "garbage\n" + second, false, 1, true, first + "garbage\n" + second},
+		{"batch", first + batch + second, false, 4, false, first + batch + second},
+		{"torn batch", first + batch[:len(batch)/2], false, 1, false, first},
+		{"unterminated batch", first + batch[:len(batch)-1], false, 1, false, first},
+		{"torn final record read-only", first + `{"op":"pu`, true, 1, false, first + `{"op":"pu`},
+		{"corrupt record read-only", first + "garbage\n" + second, true, 1, true, first + "garbage\n" + second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage.json.wal")
+			if tt.log != "" {
+				if err := os.WriteFile(path, []byte(tt.log), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			wal, err := openWAL(path, tt.readOnly)
+			if err != nil {
+				t.Fatalf("openWAL: %v", err)
+			}
+			defer wal.close()
+
+			var replayed []walRecord
+			err = wal.replay(func(record walRecord) error {
+				replayed = append(replayed, record)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replay error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(replayed) != tt.wantCount {
+				t.Errorf("replayed %d records, want %d", len(replayed), tt.wantCount)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			if string(got) != tt.wantLog {
+				t.Errorf("log after replay = %q, want %q", got, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestWALAppendAfterTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json.wal")
+	torn := `{"op":"put","collection":"users","id":"a","data":{}}` + "\n" + `{"op":"pu`
+	if err := os.WriteFile(path, []byte(torn), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wal, err := openWAL(path, false)
+	if err != nil {
+		t.Fatalf("openWAL: %v", err)
+	}
+	if err := wal.replay(func(walRecord) error { return nil }); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if err := wal.append(deleteRecord("users", "a")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	wal.close()
+
+	// The next append starts on a clean line, so the log replays in full.
+	wal, err = openWAL(path, false)
+	if err != nil {
+		t.Fatalf("openWAL: %v", err)
+	}
+	defer wal.close()
+
+	count := 0
+	if err := wal.replay(func(walRecord) error { count++; return nil }); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("replayed %d records, want 2", count)
+	}
+}
+
+func TestWALAppendBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json.wal")
+
+	wal, err := openWAL(path, false)
+	if err != nil {
+		t.Fatalf("openWAL: %v", err)
+	}
+	defer wal.close()
+
+	if err := wal.append(deleteRecord("users", "a"), deleteRecord("items", "b")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if wal.entries != 2 {
+		t.Errorf("entries = %d, want 2", wal.entries)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(got), "\n"); lines != 1 {
+		t.Errorf("batch written as %d lines, want 1: %q", lines, got)
+	}
+
+	var replayed []walRecord
+	if err := wal.replay(func(record walRecord) error {
+		replayed = append(replayed, record)
+		return nil
+	}); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	want := []walRecord{deleteRecord("users", "a"), deleteRecord("items", "b")}
+	if !reflect.DeepEqual(replayed, want) {
+		t.Errorf("replayed %+v, want %+v", replayed, want)
+	}
+}
+
+func TestWALRefusesAppendsAfterFailedRepair(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json.wal")
+
+	wal, err := openWAL(path, false)
+	if err != nil {
+		t.Fatalf("openWAL: %v", err)
+	}
+	defer wal.close()
+
+	// Writes and truncation both fail on a read-only handle, so the failed
+	// append cannot be cut off again.
+	writable := wal.file
+	wal.file, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.append(deleteRecord("users", "a")); err == nil {
+		t.Fatal("append succeeded on a read-only file")
+	}
+	wal.file.Close()
+	wal.file = writable
+
+	if err := wal.append(deleteRecord("users", "a")); err == nil {
+		t.Fatal("append succeeded after a failed repair")
+	}
+
+	if err := wal.reset(); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := wal.append(deleteRecord("users", "a")); err != nil {
+		t.Errorf("append after reset: %v", err)
+	}
+}